@@ -0,0 +1,380 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+// What the arguments of a command complete to
+const (
+	completeFiles    = "files"
+	completeTracked  = "tracked"
+	completeCommands = "commands"
+	completeShells   = "shells"
+	completeConfig   = "config"
+)
+
+// command is a myd subcommand. setup registers the command's flags and
+// returns the function that runs it with the remaining arguments.
+type command struct {
+	name     string
+	args     string // argument synopsis shown in help
+	summary  string
+	minArgs  int
+	maxArgs  int // -1 for any number
+	complete string
+	// flagValues maps flags taking a value to what the value completes to
+	flagValues map[string]string
+	hidden     bool
+	rawArgs    bool // pass every argument through without parsing flags
+	ownConfig  bool // loads the config itself, so a broken file can be fixed
+	setup      func(fs *flag.FlagSet) func(config *internal.MydConfig, args []string)
+}
+
+// Global flags, accepted before and after the command name
+var globals = struct {
+	configPath string
+	verbose    bool
+	json       bool
+}{}
+
+func addGlobalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&globals.configPath, "config", globals.configPath, "Path of the config file, defaults to $MYD_CONFIG or $XDG_CONFIG_HOME/myd/config.toml")
+	fs.BoolVar(&globals.verbose, "verbose", globals.verbose, "Print the git commands myd runs")
+	fs.BoolVar(&globals.json, "json", globals.json, "Print list, status, diff, log, install and installed results as JSON")
+}
+
+func runWith(handler func(config *internal.MydConfig, args []string)) func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+	return func(*flag.FlagSet) func(*internal.MydConfig, []string) {
+		return handler
+	}
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "init", summary: "Initialize with GitHub token", setup: runWith(func(config *internal.MydConfig, args []string) {
+			internal.ChangeToken(config, &internal.User{})
+		})},
+		{name: "add", args: "{PATH OR PATTERN...}", summary: "Add paths or glob patterns to the upload list, - reads them from stdin", maxArgs: -1, complete: completeFiles,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				interactive := fs.Bool("i", false, "Pick paths in a file browser")
+				return func(config *internal.MydConfig, args []string) {
+					if *interactive {
+						handleAddMenu(config)
+						return
+					}
+					if len(args) < 1 {
+						internal.Exit("Error: Path required for add command", nil)
+					}
+					handleAdd(args, config)
+				}
+			}},
+		{name: "upload", summary: "Upload files to GitHub",
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				message := fs.String("m", "", "Commit message, overrides commit.template")
+				return func(config *internal.MydConfig, args []string) {
+					handleUpload(config, &internal.User{}, *message)
+				}
+			}},
+		{name: "ignore", args: "[PATH OR PATTERN...]", summary: "Ignore a path or pattern inside a tracked directory, no path opens a tree view", maxArgs: -1, complete: completeFiles,
+			flagValues: map[string]string{"in": completeTracked},
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				scope := fs.String("in", "", "Tracked directory the raw gitignore patterns apply to")
+				return func(config *internal.MydConfig, args []string) {
+					if len(args) < 1 {
+						handleIgnoreMenu(*scope, config)
+						return
+					}
+					handleIgnore(args, *scope, config)
+				}
+			}},
+		{name: "unignore", args: "{PATH OR PATTERN...}", summary: "Include an ignored path again", minArgs: 1, maxArgs: -1, complete: completeFiles,
+			flagValues: map[string]string{"in": completeTracked},
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				scope := fs.String("in", "", "Tracked directory the raw gitignore patterns are removed from")
+				return func(config *internal.MydConfig, args []string) {
+					handleUnignore(args, *scope, config)
+				}
+			}},
+		{name: "list", summary: "List tracked paths", setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleList(config)
+		})},
+		{name: "status", summary: "Show the repository, last upload, sync state and skipped files", setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleStatus(config)
+		})},
+		{name: "delete", args: "[PATH...]", summary: "Delete paths from tracking in a menu, paths are only read when stdin is not a terminal", maxArgs: -1, complete: completeTracked,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				purge := fs.Bool("purge", false, "Also remove the untracked files from the repository")
+				rewriteHistory := fs.Bool("rewrite-history", false, "With --purge, drop the files from every snapshot and force push")
+				yes := fs.Bool("yes", false, "Rewrite history without asking, needed when stdin is not a terminal")
+				return func(config *internal.MydConfig, args []string) {
+					// The menu needs a terminal, scripts get the untrack behaviour instead
+					if !stdinIsTerminal() {
+						if len(args) == 0 {
+							args = []string{"-"}
+						}
+						handleUntrack(config, args, purgeOptions{*purge, *rewriteHistory, *yes})
+						return
+					}
+					handleDelete(config, purgeOptions{*purge, *rewriteHistory, *yes})
+				}
+			}},
+		{name: "untrack", args: "{PATH OR PATTERN...}", summary: "Stop tracking paths or patterns without the menu, - reads them from stdin", minArgs: 1, maxArgs: -1, complete: completeTracked,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				purge := fs.Bool("purge", false, "Also remove the untracked files from the repository")
+				rewriteHistory := fs.Bool("rewrite-history", false, "With --purge, drop the files from every snapshot and force push")
+				yes := fs.Bool("yes", false, "Rewrite history without asking, needed when stdin is not a terminal")
+				return func(config *internal.MydConfig, args []string) {
+					handleUntrack(config, args, purgeOptions{*purge, *rewriteHistory, *yes})
+				}
+			}},
+		{name: "diff", args: "[PATH...]", summary: "Show changes since the last upload", maxArgs: -1, complete: completeTracked,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				stat := fs.Bool("stat", false, "Only show a summary of changed files")
+				return func(config *internal.MydConfig, args []string) {
+					handleDiff(config, args, *stat)
+				}
+			}},
+		{name: "pull", summary: "Fetch the repository and install changed files",
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				force := fs.Bool("force", false, "Overwrite live files that changed since the last upload")
+				return func(config *internal.MydConfig, args []string) {
+					handlePull(config, *force)
+				}
+			}},
+		{name: "install", args: "[URL OR PATH]", summary: "Install dotfiles from a repository, directory or archive, your own upstream without one", maxArgs: 1, complete: completeFiles,
+			flagValues: map[string]string{"root": completeFiles, "home": completeFiles},
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				interactive := fs.Bool("i", false, "Choose the entries to install in a menu")
+				only := fs.String("only", "", "Only install these comma separated entries, names, targets or patterns")
+				exclude := fs.String("exclude", "", "Skip these comma separated entries")
+				root := fs.String("root", "", "Install into this directory instead of /, e.g. a mounted image")
+				home := fs.String("home", "", "Home directory to install to, inside --root when given")
+				scripts := fs.Bool("scripts", false, "Run the hooks and scripts of a repository other than your own upstream")
+				return func(config *internal.MydConfig, args []string) {
+					source := ""
+					if len(args) > 0 {
+						source = args[0]
+					}
+					target, err := installTarget(*root, *home)
+					if err != nil {
+						internal.Exit("", err)
+					}
+					handleInstall(source, config, installOptions{only: splitList(*only), exclude: splitList(*exclude), interactive: *interactive, target: target, scripts: *scripts})
+				}
+			}},
+		{name: "uninstall", args: "[SOURCE]", summary: "Remove the files install wrote and restore the ones it replaced", maxArgs: 1, complete: completeFiles,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				force := fs.Bool("force", false, "Also remove files changed since they were installed")
+				return func(config *internal.MydConfig, args []string) {
+					source := ""
+					if len(args) > 0 {
+						source = args[0]
+					}
+					handleUninstall(config, source, *force)
+				}
+			}},
+		{name: "installed", args: "[SOURCE]", summary: "List installed sources and the files changed since", maxArgs: 1, complete: completeFiles, setup: runWith(func(config *internal.MydConfig, args []string) {
+			source := ""
+			if len(args) > 0 {
+				source = args[0]
+			}
+			handleInstalled(config, source)
+		})},
+		{name: "log", summary: "List uploaded snapshots",
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				limit := fs.Int("n", 0, "Only show the last n snapshots")
+				return func(config *internal.MydConfig, args []string) {
+					handleLog(config, *limit)
+				}
+			}},
+		{name: "rollback", args: "{SNAPSHOT} [PATH...]", summary: "Restore tracked files from a snapshot", minArgs: 1, maxArgs: -1,
+			setup: func(fs *flag.FlagSet) func(*internal.MydConfig, []string) {
+				repoOnly := fs.Bool("repo-only", false, "Reset the repository without touching live files")
+				return func(config *internal.MydConfig, args []string) {
+					handleRollback(config, args[0], args[1:], *repoOnly)
+				}
+			}},
+		{name: "history", args: "{PATH}", summary: "Browse the snapshots of a tracked file", minArgs: 1, maxArgs: 1, complete: completeFiles, setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleHistory(config, args[0])
+		})},
+		{name: "config", args: "{get KEY|set KEY VALUE|list|validate}", summary: "Read, change or check config keys", minArgs: 1, maxArgs: 3, complete: completeConfig, ownConfig: true, setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleConfig(args)
+		})},
+		{name: "help", args: "[COMMAND]", summary: "Show help for a command", maxArgs: 1, complete: completeCommands, setup: runWith(func(config *internal.MydConfig, args []string) {
+			if len(args) == 0 {
+				printUsage()
+				return
+			}
+			cmd := findCommand(args[0])
+			if cmd == nil {
+				unknownCommand(args[0])
+			}
+			fs, _ := cmd.flagSet()
+			printCommandHelp(cmd, fs)
+		})},
+		{name: "completion", args: "{bash|zsh|fish}", summary: "Print a shell completion script", minArgs: 1, maxArgs: 1, complete: completeShells, setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleCompletion(args[0])
+		})},
+		{name: "__complete", args: "[WORD...]", summary: "Print completions for the given words", maxArgs: -1, hidden: true, rawArgs: true, setup: runWith(func(config *internal.MydConfig, args []string) {
+			handleComplete(config, args)
+		})},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet builds the command's flags, including the global ones
+func (c *command) flagSet() (*flag.FlagSet, func(*internal.MydConfig, []string)) {
+	fs := flag.NewFlagSet("myd "+c.name, flag.ExitOnError)
+	fs.SetOutput(os.Stdout)
+	addGlobalFlags(fs)
+	run := c.setup(fs)
+	fs.Usage = func() { printCommandHelp(c, fs) }
+	return fs, run
+}
+
+func (c *command) synopsis() string {
+	synopsis := "myd " + c.name
+	if c.hasFlags() {
+		synopsis += " [flags]"
+	}
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	return synopsis
+}
+
+func (c *command) hasFlags() bool {
+	fs, _ := c.flagSet()
+	count := 0
+	fs.VisitAll(func(*flag.Flag) { count++ })
+	return count > 3 // the global flags are always there
+}
+
+// parseArgs parses flags anywhere on the command line, not only before the
+// first argument. Everything after -- is taken as an argument.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printUsage() {
+	fmt.Println("Usage: myd [--config PATH] [--verbose] [--json] [command] [flags] [args]")
+	fmt.Println()
+	fmt.Println("Running myd without a command opens the dashboard.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Printf("  %-11s %s\n", cmd.name, cmd.summary)
+		}
+	}
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("  --config PATH  Path of the config file, defaults to $MYD_CONFIG or $XDG_CONFIG_HOME/myd/config.toml")
+	fmt.Println("  --verbose      Print the git commands myd runs")
+	fmt.Println("  --json         Print list, status, diff, log, install and installed results as JSON")
+	fmt.Println("  -e             Edit the config file")
+	fmt.Println()
+	fmt.Println("Run 'myd help <command>' for the flags of a command.")
+}
+
+func printCommandHelp(cmd *command, fs *flag.FlagSet) {
+	fmt.Printf("Usage: %s\n\n%s\n", cmd.synopsis(), cmd.summary)
+	if cmd.hasFlags() {
+		fmt.Println("\nFlags:")
+		fs.VisitAll(func(f *flag.Flag) {
+			if f.Name == "config" || f.Name == "verbose" || f.Name == "json" {
+				return
+			}
+			name, usage := flag.UnquoteUsage(f)
+			line := "  -" + f.Name
+			if len(f.Name) > 1 {
+				line = "  --" + f.Name
+			}
+			if name != "" {
+				line += " " + strings.ToUpper(name)
+			}
+			fmt.Printf("%-24s %s\n", line, usage)
+		})
+	}
+	fmt.Println("\nGlobal flags --config, --verbose and --json are accepted too.")
+}
+
+// unknownCommand exits with the closest command names as suggestions
+func unknownCommand(name string) {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		distance := levenshtein(name, cmd.name)
+		if distance <= 2 || strings.HasPrefix(cmd.name, name) {
+			candidates = append(candidates, candidate{cmd.name, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	fmt.Printf("Error: unknown command %q\n", name)
+	if len(candidates) > 0 {
+		fmt.Println("\nDid you mean:")
+		for _, c := range candidates {
+			fmt.Printf("  myd %s\n", c.name)
+		}
+	}
+	fmt.Println("\nRun 'myd help' for a list of commands.")
+	os.Exit(1)
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
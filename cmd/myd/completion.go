@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+// The completion scripts ask "myd __complete" for candidates. A last line of
+// ":files" tells the shell to fall back to its own file completion.
+const bashCompletion = `# bash completion for myd
+_myd() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    local out=($(myd __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [[ ${#out[@]} -gt 0 && "${out[${#out[@]}-1]}" == ":files" ]]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+        compopt -o filenames 2>/dev/null
+    else
+        COMPREPLY=("${out[@]}")
+    fi
+}
+complete -F _myd myd
+`
+
+const zshCompletion = `#compdef myd
+_myd() {
+    local -a out
+    out=("${(@f)$(myd __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    out=(${out:#})
+    if [[ "${out[-1]}" == ":files" ]]; then
+        _files
+    else
+        compadd -U -- "${out[@]}"
+    fi
+}
+compdef _myd myd
+`
+
+const fishCompletion = `# fish completion for myd
+function __myd_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    set -e tokens[1]
+    set -l out (myd __complete $tokens 2>/dev/null)
+    if set -q out[1]; and test "$out[-1]" = ":files"
+        __fish_complete_path (commandline -ct)
+    else
+        printf '%s\n' $out
+    end
+end
+complete -c myd -f -a '(__myd_complete)'
+`
+
+func handleCompletion(shell string) {
+	switch shell {
+	case "bash":
+		os.Stdout.WriteString(bashCompletion)
+	case "zsh":
+		os.Stdout.WriteString(zshCompletion)
+	case "fish":
+		os.Stdout.WriteString(fishCompletion)
+	default:
+		internal.Exit("", fmt.Errorf("unsupported shell %q, use bash, zsh or fish", shell))
+	}
+}
+
+// handleComplete prints the candidates for the last of words, which are the
+// words typed after "myd"
+func handleComplete(config *internal.MydConfig, words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	// Find the command, skipping global flags and their values
+	var cmd *command
+	var positional []string
+	previous := ""
+	for _, word := range words[:len(words)-1] {
+		switch {
+		case cmd != nil:
+			if !strings.HasPrefix(word, "-") {
+				positional = append(positional, word)
+			}
+		case previous == "--config" || previous == "-config":
+		case strings.HasPrefix(word, "-"):
+		default:
+			if cmd = findCommand(word); cmd == nil {
+				return
+			}
+		}
+		previous = word
+	}
+
+	var candidates []string
+	switch {
+	case previous == "--config" || previous == "-config":
+		fmt.Println(":files")
+		return
+	case cmd == nil && strings.HasPrefix(current, "-"):
+		candidates = []string{"--config", "--verbose", "--json", "-e"}
+	case cmd == nil:
+		candidates = commandNames()
+	case strings.HasPrefix(current, "-"):
+		candidates = flagNames(cmd)
+	default:
+		kind := cmd.complete
+		if name, ok := valueFlag(cmd, previous); ok {
+			kind = completeFiles
+			if valueKind, ok := cmd.flagValues[name]; ok {
+				kind = valueKind
+			}
+		}
+		switch kind {
+		case completeFiles:
+			fmt.Println(":files")
+			return
+		case completeTracked:
+			// Compare against the expanded word so ~/ finds tracked paths too
+			current = expandHome(current)
+			candidates = internal.LoadTrackedPaths(config)
+		case completeCommands:
+			candidates = commandNames()
+		case completeShells:
+			candidates = []string{"bash", "zsh", "fish"}
+		case completeConfig:
+			switch {
+			case len(positional) == 0:
+				candidates = []string{"get", "set", "list", "validate"}
+			case len(positional) == 1 && (positional[0] == "get" || positional[0] == "set"):
+				candidates = internal.ConfigKeys()
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+func flagNames(cmd *command) []string {
+	fs, _ := cmd.flagSet()
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			names = append(names, "-"+f.Name)
+		} else {
+			names = append(names, "--"+f.Name)
+		}
+	})
+	return names
+}
+
+// valueFlag reports whether word is a flag of cmd that takes a value
+func valueFlag(cmd *command, word string) (string, bool) {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return "", false
+	}
+	name := strings.TrimLeft(word, "-")
+	fs, _ := cmd.flagSet()
+	f := fs.Lookup(name)
+	if f == nil {
+		return "", false
+	}
+	if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+// handleConfig runs "myd config get|set|list|validate". It loads the config
+// itself, so set and validate still work on a file that does not load.
+func handleConfig(args []string) {
+	usage := map[string]string{"get": "get KEY", "set": "set KEY VALUE", "list": "list", "validate": "validate"}
+	synopsis, ok := usage[args[0]]
+	if !ok {
+		fmt.Printf("Error: unknown config action %q, expected get, set, list or validate\n", args[0])
+		os.Exit(2)
+	}
+	if len(args) != len(strings.Fields(synopsis)) {
+		fmt.Printf("Error: wrong number of arguments\nUsage: myd config %s\n", synopsis)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "get":
+		config := loadConfig()
+		value, err := internal.ConfigValue(&config, args[1])
+		if err != nil {
+			internal.Exit("", err)
+		}
+		switch value := value.(type) {
+		case []string:
+			for _, item := range value {
+				fmt.Println(item)
+			}
+		default:
+			fmt.Println(value)
+		}
+	case "set":
+		if err := internal.SetConfigValue(globals.configPath, args[1], args[2]); err != nil {
+			internal.Exit("", err)
+		}
+	case "list":
+		config := loadConfig()
+		for _, key := range internal.ConfigKeys() {
+			value, _ := internal.ConfigValue(&config, key)
+			fmt.Printf("%s = %s\n", key, internal.FormatConfigValue(value))
+		}
+	case "validate":
+		problems := internal.ValidateConfig(globals.configPath)
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("Config is valid")
+	}
+}
+
+func loadConfig() internal.MydConfig {
+	config, err := internal.LoadConfig(globals.configPath)
+	if err != nil {
+		internal.Exit("Failed to load config", err)
+	}
+	return config
+}
+
+// editConfig opens the config in $EDITOR until it validates, jumping to the
+// line of the first problem
+func editConfig() {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vim" // fallback to vim if EDITOR is not set
+	}
+	// Creates the file on first use, a broken file is still opened
+	internal.LoadConfig(globals.configPath)
+	configPath := os.ExpandEnv(globals.configPath)
+
+	line := 0
+	for {
+		args := strings.Fields(editor)
+		args = append(args, editorLineArgs(args[0], line)...)
+		cmd := exec.Command(args[0], append(args[1:], configPath)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			internal.Exit("Failed to edit config", err)
+		}
+
+		problems := internal.ValidateConfig(configPath)
+		if len(problems) == 0 {
+			return
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		fmt.Print("Press enter to fix the config, or q to keep it as it is: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil || strings.TrimSpace(strings.ToLower(answer)) == "q" {
+			os.Exit(1)
+		}
+		line = problems[0].Line
+	}
+}
+
+// editorLineArgs returns the arguments that open a file at line for the
+// editors that support it
+func editorLineArgs(editor string, line int) []string {
+	if line <= 0 {
+		return nil
+	}
+	switch filepath.Base(editor) {
+	case "vi", "vim", "nvim", "nano", "emacs", "emacsclient", "micro", "kak":
+		return []string{fmt.Sprintf("+%d", line)}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+// installOptions narrows down what install writes
+type installOptions struct {
+	only        []string
+	exclude     []string
+	interactive bool
+	target      internal.InstallTarget
+	scripts     bool // run the repository's hooks and scripts even when it is not the own upstream
+}
+
+// installTarget checks --root and --home. The home is an absolute path as
+// seen inside the root.
+func installTarget(root, home string) (internal.InstallTarget, error) {
+	var target internal.InstallTarget
+	if root != "" {
+		abs, err := filepath.Abs(expandHome(root))
+		if err != nil {
+			return target, err
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return target, fmt.Errorf("--root %s is not a directory", root)
+		}
+		target.Root = abs
+	}
+	if home != "" {
+		if !filepath.IsAbs(home) {
+			return target, fmt.Errorf("--home %s must be an absolute path", home)
+		}
+		target.Home = filepath.Clean(home)
+	}
+	return target, nil
+}
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// entryMatches reports whether a --only or --exclude value names the entry,
+// by its name, stored target, tracked pattern or destination, with glob
+// patterns allowed
+func entryMatches(entry internal.ManifestEntry, pattern string) bool {
+	for _, candidate := range []string{entry.Name, entry.Target, entry.Pattern, entry.Dest()} {
+		if candidate == "" {
+			continue
+		}
+		if candidate == pattern || candidate == expandHome(pattern) {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, candidate); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// selectEntries applies --only and --exclude, failing on values that match
+// nothing so typos do not go unnoticed
+func selectEntries(entries []internal.ManifestEntry, only, exclude []string) ([]internal.ManifestEntry, error) {
+	for _, pattern := range append(append([]string{}, only...), exclude...) {
+		found := false
+		for _, entry := range entries {
+			found = found || entryMatches(entry, pattern)
+		}
+		if !found {
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name
+			}
+			return nil, fmt.Errorf("%s matches no entry, available: %s", pattern, strings.Join(names, ", "))
+		}
+	}
+
+	var selected []internal.ManifestEntry
+	for _, entry := range entries {
+		included := len(only) == 0
+		for _, pattern := range only {
+			included = included || entryMatches(entry, pattern)
+		}
+		for _, pattern := range exclude {
+			included = included && !entryMatches(entry, pattern)
+		}
+		if included {
+			selected = append(selected, entry)
+		}
+	}
+	return selected, nil
+}
+
+// installSource is a dotfiles tree ready to install from
+type installSource struct {
+	name    string // shown in messages, never contains credentials
+	url     string // clone URL, directory or archive the files came from
+	commit  string // empty unless the source is a git repository
+	own     bool   // the user's own upstream, whose hooks and scripts are trusted
+	dir     string
+	cleanup func()
+}
+
+var shorthandRepo = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// openInstallSource resolves what install was given: nothing for the own
+// upstream, a local directory, a .tar.gz or .zip export, an owner/name
+// shorthand or anything git clone accepts
+func openInstallSource(config *internal.MydConfig, source string) (*installSource, error) {
+	if source != "" {
+		if info, err := os.Stat(expandHome(source)); err == nil {
+			path, _ := filepath.Abs(expandHome(source))
+			if info.IsDir() {
+				return &installSource{name: path, url: path, commit: sourceCommit(path), dir: path, cleanup: func() {}}, nil
+			}
+			if internal.IsArchive(path) {
+				return extractInstallSource(config, path)
+			}
+			return nil, fmt.Errorf("%s is neither a directory nor a .tar.gz or .zip archive", source)
+		}
+	}
+
+	url, name := source, source
+	owner, _, ownName := strings.Cut(remoteName(config), "/")
+	if !ownName {
+		owner = ""
+	}
+	switch {
+	case source == "" && owner == "":
+		return nil, fmt.Errorf("no GitHub username is known yet, run 'myd init' or pass a repository")
+	case source == "":
+		name = remoteName(config)
+		url = fmt.Sprintf("https://github.com/%s.git", name)
+	case shorthandRepo.MatchString(source):
+		url = fmt.Sprintf("https://github.com/%s.git", strings.TrimSuffix(source, ".git"))
+	}
+
+	// The stored token is only sent for the user's own repositories
+	var token string
+	if owner != "" && strings.HasPrefix(strings.ToLower(url), strings.ToLower("https://github.com/"+owner+"/")) {
+		if data, err := os.ReadFile(filepath.Join(os.ExpandEnv(config.StoragePath), "token")); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	src, err := cloneInstallSource(config, url, name, token)
+	if err == nil {
+		src.own = source == "" || strings.EqualFold(strings.TrimSuffix(source, ".git"), remoteName(config))
+	}
+	return src, err
+}
+
+// repoName returns the repository name of a clone URL, for the https, ssh
+// and scp-like forms with or without .git
+func repoName(url string) string {
+	url = strings.TrimRight(url, "/")
+	url = strings.TrimSuffix(url, ".git")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return url
+}
+
+func installTempDir(config *internal.MydConfig, name string) (string, error) {
+	parent := filepath.Join(os.ExpandEnv(config.StoragePath), "temp")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(parent, "install-"+name+"-")
+}
+
+func cloneInstallSource(config *internal.MydConfig, url, name, token string) (*installSource, error) {
+	tempDir, err := installTempDir(config, repoName(url))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	internal.Progress("Cloning %s...\n", name)
+	cmd := exec.Command("git", "clone", "--quiet", url, tempDir)
+	cmd.Env = os.Environ()
+	if token != "" {
+		// Passed through the environment so it shows up in neither the
+		// process list nor the clone's config
+		header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token))
+		cmd.Env = append(cmd.Env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.https://github.com/.extraheader", "GIT_CONFIG_VALUE_0="+header)
+	}
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to clone %s: %s", name, strings.TrimSpace(string(output)))
+	}
+	return &installSource{name: name, url: url, commit: sourceCommit(tempDir), dir: tempDir, cleanup: cleanup}, nil
+}
+
+func extractInstallSource(config *internal.MydConfig, archive string) (*installSource, error) {
+	base := filepath.Base(archive)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	tempDir, err := installTempDir(config, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	internal.Progress("Extracting %s...\n", archive)
+	if err := internal.ExtractArchive(archive, tempDir); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	// GitHub exports wrap the repository in a single name-branch directory
+	dir := tempDir
+	if entries, err := os.ReadDir(tempDir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		dir = filepath.Join(tempDir, entries[0].Name())
+	}
+	return &installSource{name: archive, url: archive, dir: dir, cleanup: cleanup}, nil
+}
+
+// sourceCommit returns the commit checked out in dir, if it is a git repository
+func sourceCommit(dir string) string {
+	commit, err := internal.Git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return commit
+}
+
+// findInstallRecord picks the record of source, or the only record when no
+// source is given
+func findInstallRecord(config *internal.MydConfig, source string) (*internal.InstallRecord, error) {
+	records, err := internal.LoadInstallRecords(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("nothing was installed with myd install")
+	}
+
+	sources := make([]string, len(records))
+	for i, record := range records {
+		sources[i] = record.Source
+	}
+	if source == "" {
+		if len(records) == 1 {
+			return records[0], nil
+		}
+		return nil, fmt.Errorf("several sources are installed, pick one of: %s", strings.Join(sources, ", "))
+	}
+
+	abs, _ := filepath.Abs(expandHome(source))
+	for _, record := range records {
+		if record.Source == source || record.Source == abs {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("%s was not installed, installed sources: %s", source, strings.Join(sources, ", "))
+}
+
+func handleUninstall(config *internal.MydConfig, source string, force bool) {
+	record, err := findInstallRecord(config, source)
+	if err != nil {
+		internal.Exit("", err)
+	}
+
+	count := len(record.Files)
+	kept, err := record.Uninstall(force)
+	if err != nil {
+		internal.Exit("Failed to uninstall", err)
+	}
+	for _, path := range kept {
+		internal.Warn("%s changed since it was installed, left alone\n", path)
+	}
+	if len(kept) > 0 {
+		fmt.Printf("Uninstalled %d of %d files from %s, run with --force to remove the changed ones too\n", count-len(kept), count, record.Source)
+		os.Exit(1)
+	}
+	noun := "files"
+	if count == 1 {
+		noun = "file"
+	}
+	fmt.Printf("Uninstalled %s, %d %s removed or restored\n", record.Source, count, noun)
+}
+
+// handleInstalled lists the installed sources and the files that changed
+// since, or every file of one source when it is given
+func handleInstalled(config *internal.MydConfig, source string) {
+	var records []*internal.InstallRecord
+	if source != "" {
+		record, err := findInstallRecord(config, source)
+		if err != nil {
+			internal.Exit("", err)
+		}
+		records = []*internal.InstallRecord{record}
+	} else {
+		var err error
+		if records, err = internal.LoadInstallRecords(config); err != nil {
+			internal.Exit("Failed to read the install records", err)
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].Source < records[j].Source })
+	}
+
+	list := installedListJSON{Version: jsonVersion, Sources: []installedSourceJSON{}}
+	for _, record := range records {
+		entry := installedSourceJSON{Source: record.Source, URL: record.URL, Time: record.Time, Files: []installedFileJSON{}}
+		if record.Commit != "" {
+			commit := record.Commit
+			entry.Commit = &commit
+		}
+		for _, file := range record.Files {
+			state, err := file.State()
+			if err != nil {
+				internal.Warn("Cannot read %s: %v\n", file.Path, err)
+				state = "unknown"
+			}
+			entry.Files = append(entry.Files, installedFileJSON{Path: file.Path, State: state})
+		}
+		list.Sources = append(list.Sources, entry)
+	}
+
+	if globals.json {
+		printJSON(list)
+		return
+	}
+	if len(list.Sources) == 0 {
+		fmt.Println("Nothing was installed with myd install")
+		return
+	}
+
+	for i, entry := range list.Sources {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(entry.Source)
+		from := entry.URL
+		if entry.Commit != nil {
+			from += " at " + (*entry.Commit)[:min(7, len(*entry.Commit))]
+		}
+		if !entry.Time.IsZero() {
+			fmt.Printf("  Installed from %s on %s\n", from, entry.Time.Local().Format("2006-01-02 15:04"))
+		} else if from != "" {
+			fmt.Printf("  Installed from %s\n", from)
+		}
+
+		var drifted []installedFileJSON
+		for _, file := range entry.Files {
+			if file.State != "installed" {
+				drifted = append(drifted, file)
+			}
+		}
+		switch {
+		case source != "":
+			for _, file := range entry.Files {
+				fmt.Printf("  %s (%s)\n", file.Path, file.State)
+			}
+		case len(drifted) == 0:
+			fmt.Printf("  %d files, all as installed\n", len(entry.Files))
+		default:
+			fmt.Printf("  %d files, %d changed since:\n", len(entry.Files), len(drifted))
+			for _, file := range drifted {
+				fmt.Printf("    %s (%s)\n", file.Path, file.State)
+			}
+		}
+	}
+}
@@ -1,105 +1,113 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"context"
 	"strings"
 	"time"
-	"os/exec"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/google/go-github/v60/github"
-	"golang.org/x/oauth2"
 	"github.com/wraient/myd/internal"
+	"golang.org/x/oauth2"
 )
 
 func main() {
-	// Load config from default location
-	config, err := internal.LoadConfig("$HOME/.config/myd/config")
-	if err != nil {
-		internal.Exit("Failed to load config", err)
+	root := flag.NewFlagSet("myd", flag.ContinueOnError)
+	root.SetOutput(os.Stdout)
+	root.Usage = printUsage
+	addGlobalFlags(root)
+	edit := root.Bool("e", false, "Edit config file")
+	if err := root.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(2)
 	}
-	internal.SetGlobalConfig(&config)
 
-	if len(os.Args) < 2 {
-		printUsage()
-		return
+	var cmd *command
+	var run func(*internal.MydConfig, []string)
+	var args []string
+	if root.NArg() > 0 {
+		if cmd = findCommand(root.Arg(0)); cmd == nil {
+			unknownCommand(root.Arg(0))
+		}
+		var fs *flag.FlagSet
+		fs, run = cmd.flagSet()
+		if cmd.rawArgs {
+			args = root.Args()[1:]
+		} else {
+			args = parseArgs(fs, root.Args()[1:])
+		}
 	}
-
-	// Create user struct
-	user := &internal.User{}
-
-	switch os.Args[1] {
-	case "init":
-		internal.ChangeToken(&config, user)
-	case "add":
-		if len(os.Args) < 3 {
-			internal.Exit("Error: Path required for add command", nil)
-		}
-		handleAdd(os.Args[2], &config)
-	case "upload":
-		handleUpload(&config, user)
-	case "ignore":
-		if len(os.Args) < 3 {
-			internal.Exit("Error: Path required for ignore command", nil)
-		}
-		handleIgnore(os.Args[2], &config)
-	case "list":
-		handleList(&config)
-	case "delete":
-		handleDelete(&config)
-	case "install":
-		if len(os.Args) < 3 {
-			internal.Exit("Error: GitHub repository URL required", nil)
-		}
-		handleInstall(os.Args[2], &config)
-	case "-e":
-		editConfig(&config)
-	default:
-		printUsage()
+	internal.Verbose = globals.verbose
+	if globals.configPath == "" {
+		globals.configPath = internal.DefaultConfigPath()
 	}
-}
 
-func editConfig(config *internal.MydConfig) {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "vim" // fallback to vim if EDITOR is not set
+	if *edit {
+		editConfig()
+		return
+	}
+	if cmd != nil && (len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs)) {
+		fmt.Printf("Error: wrong number of arguments\nUsage: %s\n", cmd.synopsis())
+		os.Exit(2)
 	}
 
-	configPath := os.ExpandEnv("$HOME/.config/myd/config")
-	cmd := exec.Command(editor, configPath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Load config from the default location or --config
+	var config internal.MydConfig
+	if cmd == nil || !cmd.ownConfig {
+		config = loadConfig()
+	}
+	internal.SetGlobalConfig(&config)
 
-	if err := cmd.Run(); err != nil {
-		internal.Exit("Failed to edit config", err)
+	if cmd == nil {
+		handleDashboard(&config)
+		return
 	}
+	run(&config, args)
 }
 
-func printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  myd init   - Initialize with GitHub token")
-	fmt.Println("  myd add    - Add path to upload list")
-	fmt.Println("  myd upload - Upload files to GitHub")
-	fmt.Println("  myd ignore - Add path to .gitignore")
-	fmt.Println("  myd list   - List tracked paths")
-	fmt.Println("  myd delete - Delete paths from tracking")
-	fmt.Println("  myd install - Install dotfiles from a GitHub repository")
-	fmt.Println("  myd -e     - Edit config file")
+// readsStdin reports whether pathArgs reads paths from stdin
+func readsStdin(args []string) bool {
+	for _, arg := range args {
+		if arg == "-" {
+			return true
+		}
+	}
+	return false
 }
 
-func handleAdd(path string, config *internal.MydConfig) {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		internal.Exit("Error getting absolute path", err)
+// pathArgs replaces a "-" argument with the paths read from stdin, one per line
+func pathArgs(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		if arg != "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				paths = append(paths, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			internal.Exit("Error reading paths from stdin", err)
+		}
 	}
+	return paths
+}
 
-	// Check if path exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		internal.Exit("Error: Path does not exist", nil)
+func handleAdd(args []string, config *internal.MydConfig) {
+	paths := pathArgs(args)
+	if len(paths) == 0 {
+		internal.Exit("Error: Path required for add command", nil)
 	}
 
 	// Create storage directory if it doesn't exist
@@ -109,38 +117,74 @@ func handleAdd(path string, config *internal.MydConfig) {
 	}
 
 	// Read existing paths
-	uploadListPath := filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
 	existingPaths := make(map[string]bool)
-	
-	if data, err := os.ReadFile(uploadListPath); err == nil {
-		for _, line := range strings.Split(string(data), "\n") {
-			if line = strings.TrimSpace(line); line != "" {
-				existingPaths[line] = true
+	for _, path := range internal.LoadTrackedPaths(config) {
+		existingPaths[path] = true
+	}
+
+	var added []string
+	failed := 0
+	for _, path := range paths {
+		absPath, err := filepath.Abs(expandHome(path))
+		if err != nil {
+			internal.Exit("Error getting absolute path", err)
+		}
+
+		// Patterns are stored as is and expanded on every upload
+		if internal.IsGlob(absPath) {
+			if matches, err := internal.ExpandGlob(absPath); err == nil && len(matches) == 0 {
+				internal.Warn("%s matches nothing yet\n", absPath)
 			}
+		} else if _, err := os.Stat(absPath); os.IsNotExist(err) {
+			fmt.Printf("Error: Path %s does not exist\n", absPath)
+			failed++
+			continue
 		}
+
+		// Check if path already exists
+		if existingPaths[absPath] {
+			fmt.Printf("Path %s is already in upload list\n", absPath)
+			continue
+		}
+		existingPaths[absPath] = true
+		added = append(added, absPath)
 	}
 
-	// Check if path already exists
-	if existingPaths[absPath] {
-		fmt.Printf("Path %s is already in upload list\n", absPath)
-		return
+	if len(added) > 0 {
+		appendTrackedPaths(config, added)
+	}
+	if failed > 0 {
+		internal.Exit("", fmt.Errorf("%d path(s) could not be added", failed))
 	}
+}
 
-	// Add new path to toupload.txt
-	f, err := os.OpenFile(uploadListPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func handleAddMenu(config *internal.MydConfig) {
+	model, err := internal.NewAddModel(config, os.Getenv("HOME"))
 	if err != nil {
-		internal.Exit("Error opening toupload.txt", err)
+		internal.Exit("Error opening file browser", err)
+	}
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		internal.Exit("Error running file browser", err)
 	}
-	defer f.Close()
 
-	// Write the absolute path to toupload.txt
-	if _, err := f.WriteString(absPath + "\n"); err != nil {
-		internal.Exit("Error writing to toupload.txt", err)
+	for _, path := range model.Saved() {
+		fmt.Printf("Added %s to upload list\n", path)
 	}
+}
 
-	fmt.Printf("Added %s to upload list\n", absPath)
+func appendTrackedPaths(config *internal.MydConfig, added []string) {
+	if err := internal.AppendTrackedPaths(config, added); err != nil {
+		internal.Exit("Error writing to toupload.txt", err)
+	}
+	for _, absPath := range added {
+		fmt.Printf("Added %s to upload list\n", absPath)
+	}
 }
 
+// copyFile writes src to dst as a regular file. A symlink at dst is replaced
+// rather than written through, so its target, often a file managed by
+// another tool, stays untouched.
 func copyFile(src, dst string) error {
 	input, err := os.ReadFile(src)
 	if err != nil {
@@ -152,10 +196,17 @@ func copyFile(src, dst string) error {
 		return err
 	}
 
+	if existing, err := os.Lstat(dst); err == nil && existing.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
 	return os.WriteFile(dst, input, info.Mode())
 }
 
-func copyDir(src, dst string, skipOriginalPath bool) error {
+// copyDir copies a directory tree. ignored, when set, is asked about every
+// path relative to src and ignored directories are skipped entirely.
+func copyDir(src, dst string, skipOriginalPath bool, ignored func(rel string, isDir bool) bool) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -172,6 +223,13 @@ func copyDir(src, dst string, skipOriginalPath bool) error {
 			return err
 		}
 
+		if ignored != nil && rel != "." && ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		destPath := filepath.Join(dst, rel)
 
 		if info.IsDir() {
@@ -182,13 +240,21 @@ func copyDir(src, dst string, skipOriginalPath bool) error {
 	})
 }
 
-func copyFilesToRepo(config *internal.MydConfig, repoPath string) error {
+// copyFilesToRepo copies every tracked path into repoPath. The returned
+// policy lists the files that were skipped or stored through Git LFS.
+func copyFilesToRepo(config *internal.MydConfig, repoPath string) (*internal.FilePolicy, error) {
 	// Read toupload.txt
 	uploadListPath := filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
 	paths, err := os.ReadFile(uploadListPath)
 	if err != nil {
-		return fmt.Errorf("failed to read toupload.txt: %v", err)
+		return nil, fmt.Errorf("failed to read toupload.txt: %v", err)
+	}
+
+	rules, err := internal.LoadIgnoreRules(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore rules: %v", err)
 	}
+	policy := internal.NewFilePolicy(config)
 
 	// Process each path
 	for _, path := range strings.Split(string(paths), "\n") {
@@ -197,67 +263,179 @@ func copyFilesToRepo(config *internal.MydConfig, repoPath string) error {
 			continue
 		}
 
-		fmt.Printf("Processing path: %s\n", path)
+		internal.Progress("Processing path: %s\n", path)
+
+		if internal.IsGlob(path) {
+			if err := copyGlobToRepo(path, repoPath, rules.Matcher(path), policy); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
 		// Get file info
 		info, err := os.Stat(path)
 		if err != nil {
-			fmt.Printf("Warning: Skipping %s: %v\n", path, err)
+			internal.Warn("Skipping %s: %v\n", path, err)
 			continue
 		}
 
-		// Replace $HOME and username with environment variables
-		home := os.Getenv("HOME")
-		username := os.Getenv("USER")
-		originalPath := path
-		if home != "" {
-			originalPath = strings.ReplaceAll(originalPath, home, "$HOME")
-		}
-		if username != "" {
-			originalPath = strings.ReplaceAll(originalPath, username, "$USER")
-		}
+		originalPath := placeholderPath(path)
 
 		// Get destination path
 		destPath := filepath.Join(repoPath, filepath.Base(path))
-		fmt.Printf("Copying to: %s\n", destPath)
+		internal.Progress("Copying to: %s\n", destPath)
 
 		if info.IsDir() {
 			// For directories, copy the entire directory
-			if err := copyDir(path, destPath, false); err != nil {
-				return fmt.Errorf("failed to copy directory %s: %v", path, err)
+			filter := uploadFilter(path, "", filepath.Base(path), rules.Matcher(path), policy)
+			if err := copyDir(path, destPath, false, filter); err != nil {
+				return nil, fmt.Errorf("failed to copy directory %s: %v", path, err)
 			}
-			
+
 			// Create .original_path inside the copied directory
 			originalPathFile := filepath.Join(destPath, ".original_path")
 			if err := os.WriteFile(originalPathFile, []byte(originalPath), 0644); err != nil {
-				return fmt.Errorf("failed to create .original_path in directory %s: %v", path, err)
+				return nil, fmt.Errorf("failed to create .original_path in directory %s: %v", path, err)
 			}
 		} else {
+			if !policy.Allow(path, filepath.Base(path), info.Size()) {
+				continue
+			}
+
 			// For files, copy to destination
 			if err := copyFile(path, destPath); err != nil {
-				return fmt.Errorf("failed to copy file %s: %v", path, err)
+				return nil, fmt.Errorf("failed to copy file %s: %v", path, err)
 			}
-			
+
 			// Update root .original_path file for single files
 			originalPathFile := filepath.Join(repoPath, ".original_path")
 			f, err := os.OpenFile(originalPathFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
-				return fmt.Errorf("failed to open .original_path: %v", err)
+				return nil, fmt.Errorf("failed to open .original_path: %v", err)
 			}
 			defer f.Close()
-			
+
 			if _, err := f.WriteString(originalPath + "\n"); err != nil {
-				return fmt.Errorf("failed to write to .original_path: %v", err)
+				return nil, fmt.Errorf("failed to write to .original_path: %v", err)
 			}
 		}
 
-		fmt.Printf("Successfully processed: %s\n", path)
+		internal.Progress("Successfully processed: %s\n", path)
+	}
+
+	if err := policy.WriteLFSAttributes(repoPath); err != nil {
+		return nil, fmt.Errorf("failed to write .gitattributes: %v", err)
+	}
+	return policy, nil
+}
+
+// uploadFilter combines the ignore rules and the file policy into the skip
+// callback used by copyDir. src is the directory being copied, matchRel its
+// path relative to the tracked root and repoRel its path in the repository.
+func uploadFilter(src, matchRel, repoRel string, matcher *internal.Matcher, policy *internal.FilePolicy) func(rel string, isDir bool) bool {
+	return func(rel string, isDir bool) bool {
+		if matcher.Ignored(filepath.Join(matchRel, rel), isDir) {
+			return true
+		}
+		if isDir {
+			return false
+		}
+
+		info, err := os.Stat(filepath.Join(src, rel))
+		if err != nil {
+			return false
+		}
+		return !policy.Allow(filepath.Join(src, rel), filepath.Join(repoRel, rel), info.Size())
+	}
+}
+
+// placeholderPath stores path relative to the deepest path root, e.g.
+// $XDG_CONFIG_HOME/nvim, so it installs to the same place on other machines
+func placeholderPath(path string) string {
+	roots, err := internal.PathRoots(internal.GetGlobalConfig())
+	if err != nil {
+		internal.Exit("Invalid paths.roots", err)
+	}
+	return internal.Placeholder(roots, path)
+}
+
+// copyGlobToRepo copies every path matching a pattern into its own directory,
+// keeping their paths relative to the pattern's base. The pattern goes into
+// .original_path, so install only writes the matches back.
+func copyGlobToRepo(pattern, repoPath string, matcher *internal.Matcher, policy *internal.FilePolicy) error {
+	matches, err := internal.ExpandGlob(pattern)
+	if err != nil {
+		internal.Warn("Skipping %s: %v\n", pattern, err)
+		return nil
 	}
+	if len(matches) == 0 {
+		internal.Warn("%s matches nothing\n", pattern)
+		return nil
+	}
+
+	base := internal.GlobBase(pattern)
+	name := internal.GlobEntryName(pattern)
+	destRoot := filepath.Join(repoPath, name)
+	if err := os.MkdirAll(destRoot, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", destRoot, err)
+	}
+
+	// Matches are sorted, so a directory comes before the matches inside it,
+	// which copying the directory already took care of
+	var copiedDirs []string
+	for _, match := range matches {
+		if insideAny(match, copiedDirs) {
+			continue
+		}
+		rel, err := filepath.Rel(base, match)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destRoot, rel)
 
+		info, err := os.Stat(match)
+		if err != nil {
+			internal.Warn("Skipping %s: %v\n", match, err)
+			continue
+		}
+		if matcher.Ignored(rel, info.IsDir()) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %v", match, err)
+		}
+		repoRel := filepath.Join(name, rel)
+		if info.IsDir() {
+			err = copyDir(match, destPath, false, uploadFilter(match, rel, repoRel, matcher, policy))
+			copiedDirs = append(copiedDirs, match)
+		} else if policy.Allow(match, repoRel, info.Size()) {
+			err = copyFile(match, destPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %v", match, err)
+		}
+	}
+
+	originalPathFile := filepath.Join(destRoot, ".original_path")
+	if err := os.WriteFile(originalPathFile, []byte(placeholderPath(pattern)), 0644); err != nil {
+		return fmt.Errorf("failed to create .original_path for %s: %v", pattern, err)
+	}
+
+	internal.Progress("Copied %d matches of %s\n", len(matches), pattern)
 	return nil
 }
 
-func handleUpload(config *internal.MydConfig, user *internal.User) {
+// insideAny reports whether path lies below one of dirs
+func insideAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleUpload(config *internal.MydConfig, user *internal.User, message string) {
 	// Setup GitHub client
 	tokenPath := filepath.Join(os.ExpandEnv(config.StoragePath), "token")
 	tokenBytes, err := os.ReadFile(tokenPath)
@@ -279,26 +457,27 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 	user.Username = *authenticatedUser.Login
 
 	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
-	fmt.Printf("Repository path: %s\n", repoPath)
-	
+	internal.Progress("Repository path: %s\n", repoPath)
+
 	// Check if repository exists on GitHub
 	_, resp, err := client.Repositories.Get(ctx, user.Username, config.UpstreamName)
 	repoExists := err == nil || resp.StatusCode != 404
-	fmt.Printf("Repository exists: %v\n", repoExists)
+	internal.Progress("Repository exists: %v\n", repoExists)
 
-	// Initialize local repository
-	if _, err := os.Stat(repoPath); err == nil {
-		fmt.Println("Using existing repository directory")
+	// Initialize local repository. myd add creates the directory already, so
+	// only a .git in it means there is something to reuse.
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err == nil {
+		internal.Progress("Using existing repository directory\n")
 	} else {
 		if repoExists {
-			fmt.Println("Cloning existing repository")
+			internal.Progress("Cloning existing repository\n")
 			cmd := exec.Command("git", "clone", fmt.Sprintf("https://%s@github.com/%s/%s.git", user.Token, user.Username, config.UpstreamName), repoPath)
 			output, err := cmd.CombinedOutput()
 			if err != nil {
 				internal.Exit(string(output), err)
 			}
 		} else {
-			fmt.Println("Initializing new repository")
+			internal.Progress("Initializing new repository\n")
 			if err := os.MkdirAll(repoPath, 0755); err != nil {
 				internal.Exit("Failed to create repository directory", err)
 			}
@@ -311,13 +490,27 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 		}
 	}
 
-	// Remove everything except .git from the repo
+	// Pre-upload hooks see the files the upload is going to change
+	preview, err := previewUpload(config)
+	if err != nil {
+		internal.Exit("Failed to compare with the last upload", err)
+	}
+	pending, err := preview.Changes()
+	preview.Close()
+	if err != nil {
+		internal.Exit("Failed to compare with the last upload", err)
+	}
+	if err := internal.RunHooks(config, internal.PreUpload, internal.HookEnv{Repo: repoPath, Files: changedPaths(pending)}, true); err != nil {
+		internal.Exit("", err)
+	}
+
+	// Remove everything except .git and the hooks and scripts in .myd from the repo
 	entries, err := os.ReadDir(repoPath)
 	if err != nil {
 		internal.Exit("Failed to read repository directory", err)
 	}
 	for _, entry := range entries {
-		if entry.Name() != ".git" {
+		if entry.Name() != ".git" && entry.Name() != ".myd" {
 			path := filepath.Join(repoPath, entry.Name())
 			if err := os.RemoveAll(path); err != nil {
 				internal.Exit("Failed to clean repository", err)
@@ -326,12 +519,21 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 	}
 
 	// Copy files using the new function
-	fmt.Println("Copying files to repository")
-	if err := copyFilesToRepo(config, repoPath); err != nil {
+	internal.Progress("Copying files to repository\n")
+	policy, err := copyFilesToRepo(config, repoPath)
+	if err != nil {
 		internal.Exit("Failed to copy files", err)
 	}
+	if err := policy.SaveSkipped(config); err != nil {
+		internal.Exit("Failed to record skipped files", err)
+	}
+	if len(policy.LFS) > 0 {
+		if _, err := internal.Git(repoPath, "lfs", "install", "--local"); err != nil {
+			internal.Exit("Failed to set up Git LFS", err)
+		}
+	}
 
-	fmt.Println("Staging files")
+	internal.Progress("Staging files\n")
 	cmd := exec.Command("git", "add", ".")
 	cmd.Dir = repoPath
 	output, err := cmd.CombinedOutput()
@@ -353,37 +555,22 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 		return
 	}
 
-	fmt.Println("Committing changes")
-	timeStr := time.Now().Format("2006-01-02 15:04:05")
-
-	// Configure git before committing
-	configCmd := exec.Command("git", "config", "--local", "user.name", "myd")
-	configCmd.Dir = repoPath
-	if output, err := configCmd.CombinedOutput(); err != nil {
-		internal.Exit(fmt.Sprintf("Failed to configure git user name: %s", string(output)), err)
+	internal.Progress("Committing changes\n")
+	changes, err := internal.StagedChanges(repoPath)
+	if err != nil {
+		internal.Exit("Failed to list staged changes", err)
 	}
-
-	configCmd = exec.Command("git", "config", "--local", "user.email", "myd@local")
-	configCmd.Dir = repoPath
-	if output, err := configCmd.CombinedOutput(); err != nil {
-		internal.Exit(fmt.Sprintf("Failed to configure git user email: %s", string(output)), err)
+	commitMessage, err := internal.BuildCommitMessage(repoPath, changes, config.CommitTemplate, message)
+	if err != nil {
+		internal.Exit("Failed to build commit message", err)
 	}
 
-	cmd = exec.Command("git", "commit", "-m", fmt.Sprintf("automatic update %s", timeStr))
-	cmd.Dir = repoPath
-	cmd.Env = append(os.Environ(),
-		"GIT_AUTHOR_NAME=myd",
-		"GIT_AUTHOR_EMAIL=myd@local",
-		"GIT_COMMITTER_NAME=myd",
-		"GIT_COMMITTER_EMAIL=myd@local",
-	)
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		internal.Exit(fmt.Sprintf("Failed to commit: %s", string(output)), err)
+	if err := commitRepo(config, repoPath, commitMessage, authenticatedUser); err != nil {
+		internal.Exit("Failed to commit", err)
 	}
 
 	if !repoExists {
-		fmt.Println("Creating new repository on GitHub")
+		internal.Progress("Creating new repository on GitHub\n")
 		repo := &github.Repository{
 			Name:    github.String(config.UpstreamName),
 			Private: github.Bool(true),
@@ -393,7 +580,7 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 			internal.Exit("Failed to create GitHub repository", err)
 		}
 
-		fmt.Println("Adding remote")
+		internal.Progress("Adding remote\n")
 		cmd = exec.Command("git", "remote", "add", "origin", fmt.Sprintf("https://%s@github.com/%s/%s.git", user.Token, user.Username, config.UpstreamName))
 		cmd.Dir = repoPath
 		output, err = cmd.CombinedOutput()
@@ -410,7 +597,7 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 		}
 	}
 
-	fmt.Println("Pushing changes")
+	internal.Progress("Pushing changes\n")
 	cmd = exec.Command("git", "push", "--set-upstream", "origin", "main")
 	cmd.Dir = repoPath
 	output, err = cmd.CombinedOutput()
@@ -424,209 +611,469 @@ func handleUpload(config *internal.MydConfig, user *internal.User) {
 	}
 
 	fmt.Println("Successfully uploaded files to GitHub")
+
+	if err := internal.RunHooks(config, internal.PostUpload, internal.HookEnv{Repo: repoPath, Files: changedPaths(changes)}, true); err != nil {
+		internal.Exit("", err)
+	}
 }
 
-func handleIgnore(path string, config *internal.MydConfig) {
-	absPath, err := filepath.Abs(path)
+// changedPaths returns the repository paths of changes, for MYD_FILES
+func changedPaths(changes []internal.FileChange) []string {
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = change.Path
+	}
+	return paths
+}
+
+// commitRepo commits the staged changes with the configured identity and signing
+func commitRepo(config *internal.MydConfig, repoPath, message string, ghUser *github.User) error {
+	identity, err := internal.ResolveIdentity(config, ghUser)
 	if err != nil {
-		internal.Exit("Error getting absolute path", err)
+		return err
+	}
+	globalArgs, commitArgs, err := internal.SigningArgs(config)
+	if err != nil {
+		return err
 	}
 
-	// Check if path exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-			internal.Exit("Error: Path does not exist", nil)
+	// Configure git before committing
+	if _, err := internal.Git(repoPath, "config", "--local", "user.name", identity.Name); err != nil {
+		return err
+	}
+	if _, err := internal.Git(repoPath, "config", "--local", "user.email", identity.Email); err != nil {
+		return err
 	}
 
-	// Read toupload.txt to find the base directory
-	uploadListPath := filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
-	uploadData, err := os.ReadFile(uploadListPath)
+	internal.Progress("Committing as %s <%s>\n", identity.Name, identity.Email)
+	args := append(globalArgs, "commit")
+	args = append(args, commitArgs...)
+	cmd := exec.Command("git", append(args, "-m", message)...)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), identity.Env()...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func handleIgnore(args []string, scope string, config *internal.MydConfig) {
+	tracked := internal.LoadTrackedPaths(config)
+	rules, err := internal.LoadIgnoreRules(config)
 	if err != nil {
-		internal.Exit("Error reading toupload.txt", err)
+		internal.Exit("Error reading ignore rules", err)
 	}
 
-	// Find the longest matching path from toupload.txt
-	var baseDir string
-	for _, line := range strings.Split(string(uploadData), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, arg := range args {
+		if scope != "" {
+			// Raw gitignore patterns relative to the given tracked path
+			trackedPath := resolveIgnoreScope(scope, tracked)
+			if rules.Add(trackedPath, arg) {
+				fmt.Printf("Ignoring %s in %s\n", arg, trackedPath)
+			} else {
+				fmt.Printf("Rule %s is already set for %s\n", arg, trackedPath)
+			}
 			continue
 		}
-		if strings.HasPrefix(absPath, line) && len(line) > len(baseDir) {
-			baseDir = line
+
+		negate := strings.HasPrefix(arg, "!")
+		trackedPath, rel, isDir := resolveIgnorePath(strings.TrimPrefix(arg, "!"), tracked, !negate)
+		switch {
+		case negate:
+			if err := rules.Unignore(trackedPath, rel, isDir); err != nil {
+				internal.Exit("", err)
+			}
+			fmt.Printf("Including %s in %s\n", rel, trackedPath)
+		case internal.IsGlob(rel):
+			rules.Add(trackedPath, "/"+filepath.ToSlash(rel))
+			fmt.Printf("Ignoring /%s in %s\n", filepath.ToSlash(rel), trackedPath)
+		default:
+			rules.Ignore(trackedPath, rel, isDir)
+			fmt.Printf("Ignoring %s in %s\n", rel, trackedPath)
 		}
 	}
 
-	if baseDir == "" {
-		internal.Exit("Error: Path is not within any directory in toupload.txt", nil)
+	if err := internal.SaveIgnoreRules(config, rules); err != nil {
+		internal.Exit("Error writing ignore rules", err)
 	}
+}
 
-	// Get the relative path from the base directory
-	relPath, err := filepath.Rel(baseDir, absPath)
+func handleUnignore(args []string, scope string, config *internal.MydConfig) {
+	tracked := internal.LoadTrackedPaths(config)
+	rules, err := internal.LoadIgnoreRules(config)
 	if err != nil {
-		internal.Exit("Error getting relative path", err)
+		internal.Exit("Error reading ignore rules", err)
 	}
 
-	// Join with the base name of the directory
-	repoIgnorePath := filepath.Join(filepath.Base(baseDir), relPath)
+	for _, arg := range args {
+		if scope != "" {
+			trackedPath := resolveIgnoreScope(scope, tracked)
+			if !rules.Remove(trackedPath, arg) {
+				usageError("%s has no rule %s", trackedPath, arg)
+			}
+			fmt.Printf("Removed rule %s from %s\n", arg, trackedPath)
+			continue
+		}
 
-	// Create or open .gitignore file
-	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
-	gitignorePath := filepath.Join(repoPath, ".gitignore")
-	
-	// Read existing entries
-	existingEntries := make(map[string]bool)
-	if data, err := os.ReadFile(gitignorePath); err == nil {
-		for _, line := range strings.Split(string(data), "\n") {
-			if line = strings.TrimSpace(line); line != "" {
-				existingEntries[line] = true
+		trackedPath, rel, isDir := resolveIgnorePath(arg, tracked, false)
+		if internal.IsGlob(rel) {
+			if !rules.Remove(trackedPath, "/"+filepath.ToSlash(rel)) {
+				usageError("%s has no rule /%s", trackedPath, filepath.ToSlash(rel))
 			}
+		} else if err := rules.Unignore(trackedPath, rel, isDir); err != nil {
+			internal.Exit("", err)
 		}
+		fmt.Printf("Including %s in %s\n", rel, trackedPath)
 	}
 
-	// Check if entry already exists
-	if existingEntries[repoIgnorePath] {
-		fmt.Printf("Path %s is already in .gitignore\n", repoIgnorePath)
-		return
+	if err := internal.SaveIgnoreRules(config, rules); err != nil {
+		internal.Exit("Error writing ignore rules", err)
 	}
+}
 
-	// Append new entry
-	f, err := os.OpenFile(gitignorePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func handleIgnoreMenu(scope string, config *internal.MydConfig) {
+	trackedPath := ""
+	if scope != "" {
+		trackedPath = resolveIgnoreScope(scope, internal.LoadTrackedPaths(config))
+	}
+
+	model, err := internal.NewIgnoreModel(config, trackedPath)
 	if err != nil {
-		internal.Exit("Error opening .gitignore", err)
+		internal.Exit("", err)
 	}
-	defer f.Close()
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		internal.Exit("Error running ignore menu", err)
+	}
+}
 
-	if _, err := f.WriteString(repoIgnorePath + "\n"); err != nil {
-		internal.Exit("Error writing to .gitignore", err)
+// resolveIgnoreScope makes sure the --in argument names a tracked path
+func resolveIgnoreScope(scope string, tracked []string) string {
+	absScope, err := filepath.Abs(expandHome(scope))
+	if err != nil {
+		internal.Exit("Error getting absolute path", err)
+	}
+	for _, path := range tracked {
+		if path == absScope {
+			return path
+		}
+	}
+	usageError("%s is not a tracked path", absScope)
+	return ""
+}
+
+// resolveIgnorePath finds the tracked directory containing a path or glob and
+// returns the path relative to it
+func resolveIgnorePath(arg string, tracked []string, mustExist bool) (string, string, bool) {
+	absPath, err := filepath.Abs(expandHome(arg))
+	if err != nil {
+		internal.Exit("Error getting absolute path", err)
+	}
+
+	// Check if path exists
+	isDir := false
+	if info, err := os.Stat(absPath); err == nil {
+		isDir = info.IsDir()
+	} else if mustExist && !internal.IsGlob(absPath) {
+		usageError("Path %s does not exist", absPath)
 	}
 
-	fmt.Printf("Added %s to .gitignore\n", repoIgnorePath)
+	// The pattern's fixed prefix decides which tracked path it belongs to
+	lookup := absPath
+	if internal.IsGlob(absPath) {
+		lookup = internal.GlobBase(absPath)
+	}
+	trackedPath, ok := internal.ContainingTracked(tracked, lookup)
+	if !ok {
+		usageError("%s is not within any tracked directory", absPath)
+	}
+
+	rel, err := filepath.Rel(internal.TrackedRoot(trackedPath), absPath)
+	if err != nil {
+		internal.Exit("Error getting relative path", err)
+	}
+	if rel == "." {
+		usageError("%s is tracked itself, use 'myd delete' to stop tracking it", absPath)
+	}
+	return trackedPath, rel, isDir
+}
+
+// expandHome expands a leading ~ and environment variables in a path argument
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return os.ExpandEnv(path)
 }
 
 func handleList(config *internal.MydConfig) {
-	// Read toupload.txt
-	uploadListPath := filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
-	data, err := os.ReadFile(uploadListPath)
+	paths := internal.LoadTrackedPaths(config)
+	rules, err := internal.LoadIgnoreRules(config)
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("No paths are currently being tracked")
-			return
+		internal.Exit("Error reading ignore rules", err)
+	}
+
+	if globals.json {
+		list := listJSON{Version: jsonVersion, Paths: []trackedJSON{}}
+		for _, path := range paths {
+			list.Paths = append(list.Paths, newTrackedJSON(path, rules))
 		}
-		internal.Exit("Error reading toupload.txt", err)
+		printJSON(list)
+		return
 	}
 
-	paths := strings.Split(string(data), "\n")
-	if len(paths) == 0 || (len(paths) == 1 && paths[0] == "") {
+	if len(paths) == 0 {
 		fmt.Println("No paths are currently being tracked")
 		return
 	}
 
 	fmt.Println("Currently tracked paths:")
 	for _, path := range paths {
-		path = strings.TrimSpace(path)
-		if path != "" {
+		if internal.IsGlob(path) {
+			matches, _ := internal.ExpandGlob(path)
+			fmt.Printf("  %s (pattern, %d matches)\n", path, len(matches))
+		} else {
 			fmt.Printf("  %s\n", path)
 		}
+		for _, rule := range rules[path] {
+			fmt.Printf("    ignore %s\n", rule)
+		}
 	}
 }
 
-func handleDelete(config *internal.MydConfig) {
-	p := tea.NewProgram(internal.NewDeleteModel(config))
+func handleDelete(config *internal.MydConfig, opts purgeOptions) {
+	if opts.rewriteHistory && !opts.purge {
+		usageError("--rewrite-history requires --purge")
+	}
+
+	model := internal.NewDeleteModel(config)
+	p := tea.NewProgram(model)
 	if _, err := p.Run(); err != nil {
 		internal.Exit("Error running delete menu", err)
 	}
-}
 
-func handleInstall(repoURL string, config *internal.MydConfig) {
-	// Parse GitHub URL
-	repoName := filepath.Base(repoURL)
-	repoName = strings.TrimSuffix(repoName, ".git")
+	if opts.purge && len(model.Removed()) > 0 {
+		purgeEntries(config, model.Removed(), opts)
+	}
+}
 
-	// Create temp directory for cloning
-	tempDir := filepath.Join(os.ExpandEnv(config.StoragePath), "temp", repoName)
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		internal.Exit("Failed to create temp directory", err)
+func handleInstall(source string, config *internal.MydConfig, opts installOptions) {
+	src, err := openInstallSource(config, source)
+	if err != nil {
+		internal.Exit("", err)
 	}
-	defer os.RemoveAll(tempDir)
+	defer src.cleanup()
 
-	// Clone the repository
-	fmt.Printf("Cloning %s...\n", repoURL)
-	cmd := exec.Command("git", "clone", repoURL, tempDir)
-	output, err := cmd.CombinedOutput()
+	entries, err := internal.ReadManifest(src.dir)
 	if err != nil {
-		internal.Exit(string(output), err)
+		src.cleanup()
+		internal.Exit("Failed to read repository contents", err)
+	}
+	if len(entries) == 0 {
+		src.cleanup()
+		internal.Exit("", fmt.Errorf("%s contains no dotfiles uploaded by myd", src.name))
 	}
 
-	// Read root .original_path file
-	rootOriginalPath := filepath.Join(tempDir, ".original_path")
-	if data, err := os.ReadFile(rootOriginalPath); err == nil {
-		// Handle single files
-		for _, line := range strings.Split(string(data), "\n") {
-			if line = strings.TrimSpace(line); line == "" {
-				continue
-			}
-			
-			// Expand environment variables
-			path := os.ExpandEnv(line)
-			baseName := filepath.Base(path)
-			srcPath := filepath.Join(tempDir, baseName)
-			
-			// Create parent directory if it doesn't exist
-			parentDir := filepath.Dir(path)
-			if err := os.MkdirAll(parentDir, 0755); err != nil {
-				fmt.Printf("Warning: Failed to create directory for %s: %v\n", path, err)
-				continue
-			}
+	entries, err = selectEntries(entries, opts.only, opts.exclude)
+	if err != nil {
+		src.cleanup()
+		internal.Exit("", err)
+	}
+	if opts.interactive && len(entries) > 0 {
+		model := internal.NewInstallModel(entries, opts.target)
+		if _, err := tea.NewProgram(model).Run(); err != nil {
+			src.cleanup()
+			internal.Exit("Error running install menu", err)
+		}
+		if entries = model.Chosen(); entries == nil {
+			src.cleanup()
+			internal.Exit("Nothing installed", nil)
+		}
+	}
 
-			// Copy file to original location
-			if err := copyFile(srcPath, path); err != nil {
-				fmt.Printf("Warning: Failed to copy %s: %v\n", baseName, err)
-				continue
-			}
-			fmt.Printf("Installed %s\n", path)
+	// Files in another tree belong to the owner of the home they go to
+	var owner *internal.Owner
+	if opts.target.Remapped() {
+		home := opts.target.HomeDir()
+		if owner, err = internal.FileOwner(home); err != nil {
+			internal.Warn("Cannot read the owner of %s, installed files keep the current owner: %v\n", home, err)
 		}
 	}
 
-	// Handle directories with .original_path
-	entries, err := os.ReadDir(tempDir)
+	record, err := internal.OpenInstallRecord(config, src.name)
 	if err != nil {
-		internal.Exit("Failed to read repository contents", err)
+		src.cleanup()
+		internal.Exit("Failed to read the install record", err)
+	}
+	record.URL, record.Commit, record.Time = src.url, src.commit, time.Now()
+
+	// Hooks and scripts of someone else's repository would run their code
+	// on this machine
+	trusted := src.own || opts.scripts
+	hasScripts := len(internal.RepoScripts(src.dir)) > 0
+	if !trusted && (hasScripts || len(internal.RepoHooks(src.dir, internal.PreInstall)) > 0 || len(internal.RepoHooks(src.dir, internal.PostInstall)) > 0) {
+		internal.Warn("Not running the hooks and scripts of %s, pass --scripts to run them\n", src.name)
 	}
 
+	var dests []string
 	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == ".git" {
-			continue
+		if dest, err := entry.InstallDestIn(opts.target); err == nil {
+			dests = append(dests, dest)
 		}
+	}
+	env := internal.HookEnv{Repo: src.dir, Files: dests, Target: opts.target}
+	if err := internal.RunHooks(config, internal.PreInstall, env, trusted); err != nil {
+		src.cleanup()
+		internal.Exit("", err)
+	}
 
-		dirPath := filepath.Join(tempDir, entry.Name())
-		originalPathFile := filepath.Join(dirPath, ".original_path")
-		
-		data, err := os.ReadFile(originalPathFile)
+	result := installJSON{Version: jsonVersion, Repository: src.name, Installed: []installedJSON{}, Failed: []installedJSON{}}
+	for _, entry := range entries {
+		destPath, err := installEntryIn(src.dir, entry, opts.target, owner, record)
 		if err != nil {
+			internal.Warn("Failed to install %s: %v\n", entry.Name, err)
+			result.Failed = append(result.Failed, installedJSON{Name: entry.Name, Destination: destPath, Error: err.Error()})
 			continue
 		}
+		result.Installed = append(result.Installed, installedJSON{Name: entry.Name, Destination: destPath})
+		if !globals.json {
+			fmt.Printf("Installed %s\n", destPath)
+		}
+	}
 
-		originalPath := strings.TrimSpace(string(data))
-		if originalPath == "" {
-			continue
+	if err := record.Save(); err != nil {
+		internal.Warn("Failed to save the install record, uninstall will not know about these files: %v\n", err)
+	}
+
+	// Scripts set up this machine, so they skip installs into another tree
+	var hookErr error
+	switch {
+	case trusted && hasScripts && opts.target.Remapped():
+		internal.Warn("Not running the scripts of %s when installing into another tree\n", src.name)
+	case trusted:
+		hookErr = internal.RunScripts(config, src.name, env)
+	}
+	if hookErr == nil {
+		env.Files = nil
+		for _, installed := range result.Installed {
+			env.Files = append(env.Files, installed.Destination)
 		}
+		hookErr = internal.RunHooks(config, internal.PostInstall, env, trusted)
+	}
 
-		// Expand environment variables
-		destPath := os.ExpandEnv(originalPath)
+	if globals.json {
+		printJSON(result)
+	} else {
+		fmt.Println("Installation complete!")
+	}
+	if hookErr != nil {
+		src.cleanup()
+		internal.Exit("", hookErr)
+	}
+}
 
-		// Create parent directory if it doesn't exist
-		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-			fmt.Printf("Warning: Failed to create directory for %s: %v\n", destPath, err)
-			continue
+// installEntry copies a manifest entry from a checked out repository to its original location
+func installEntry(srcDir string, entry internal.ManifestEntry) (string, error) {
+	return installEntryIn(srcDir, entry, internal.InstallTarget{}, nil, nil)
+}
+
+// installEntryIn installs an entry into target, handing the files and
+// directories it creates below the target's home to owner when one is
+// given. With a record, every file written is recorded and the files it
+// replaces are backed up first, so uninstall can undo it.
+func installEntryIn(srcDir string, entry internal.ManifestEntry, target internal.InstallTarget, owner *internal.Owner, record *internal.InstallRecord) (string, error) {
+	destPath, err := entry.InstallDestIn(target)
+	if err != nil {
+		return entry.Target, err
+	}
+	srcPath := filepath.Join(srcDir, entry.Name)
+
+	// Other roots keep the owner of whoever runs install
+	if home := target.HomeDir(); !strings.HasPrefix(destPath, strings.TrimSuffix(home, "/")+"/") {
+		owner = nil
+	}
+
+	// Create parent directory if it doesn't exist
+	created, err := internal.MkdirAllAs(filepath.Dir(destPath), owner)
+	if err != nil {
+		return destPath, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	files, dirs, err := entryPaths(srcPath, destPath, entry)
+	if err != nil {
+		return destPath, err
+	}
+	// Replaced files keep their owner, only new ones are handed over
+	newPaths := append([]string{}, dirs...)
+	for _, file := range files {
+		if _, err := os.Lstat(file); os.IsNotExist(err) {
+			newPaths = append(newPaths, file)
 		}
+	}
+	if record != nil {
+		record.CreatedDirs(created...)
+		record.CreatedDirs(dirs...)
+		for _, file := range files {
+			if err := record.Prepare(file); err != nil {
+				return destPath, err
+			}
+		}
+	}
 
-		// Copy directory to original location
-		if err := copyDir(dirPath, destPath, true); err != nil {
-			fmt.Printf("Warning: Failed to copy directory %s: %v\n", entry.Name(), err)
-			continue
+	if entry.IsDir {
+		err = copyDir(srcPath, destPath, true, entryFilter(entry))
+	} else {
+		err = copyFile(srcPath, destPath)
+	}
+	if err == nil {
+		err = internal.Chown(owner, newPaths...)
+	}
+	if record != nil {
+		for _, file := range files {
+			if err == nil {
+				err = record.Installed(file)
+			}
 		}
-		fmt.Printf("Installed %s\n", destPath)
 	}
+	return destPath, err
+}
 
-	fmt.Println("Installation complete!")
+// entryFilter skips the files of a pattern entry that are no match, a
+// repository should not have any but install never writes outside them
+func entryFilter(entry internal.ManifestEntry) func(rel string, isDir bool) bool {
+	if entry.Pattern == "" {
+		return nil
+	}
+	return func(rel string, isDir bool) bool {
+		return !isDir && !entry.Contains(rel)
+	}
+}
+
+// entryPaths lists the files installing an entry writes and the
+// directories it creates inside the entry, outermost first
+func entryPaths(srcPath, destPath string, entry internal.ManifestEntry) ([]string, []string, error) {
+	if !entry.IsDir {
+		return []string{destPath}, nil, nil
+	}
+
+	var files, dirs []string
+	err := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".original_path" {
+			return nil
+		}
+		rel, _ := filepath.Rel(srcPath, path)
+		dest := filepath.Join(destPath, rel)
+		if info.IsDir() {
+			if _, err := os.Lstat(dest); os.IsNotExist(err) {
+				dirs = append(dirs, dest)
+			}
+		} else if entry.Contains(rel) {
+			files = append(files, dest)
+		}
+		return nil
+	})
+	return files, dirs, err
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/wraient/myd/internal"
+)
+
+// jsonVersion is bumped whenever a field of the --json documents changes meaning or is removed
+const jsonVersion = 1
+
+type trackedJSON struct {
+	Path    string   `json:"path"`
+	Pattern bool     `json:"pattern"`
+	Matches *int     `json:"matches"` // null for plain paths
+	Ignore  []string `json:"ignore"`
+}
+
+type listJSON struct {
+	Version int           `json:"version"`
+	Paths   []trackedJSON `json:"paths"`
+}
+
+type snapshotJSON struct {
+	Hash    string    `json:"hash"`
+	Date    time.Time `json:"date"`
+	Host    string    `json:"host"`
+	Subject string    `json:"subject"`
+	Paths   []string  `json:"paths"`
+}
+
+type logJSON struct {
+	Version   int            `json:"version"`
+	Snapshots []snapshotJSON `json:"snapshots"`
+}
+
+type pathStateJSON struct {
+	trackedJSON
+	State string `json:"state"` // synced, modified, not uploaded, missing or unknown
+}
+
+type skippedJSON struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+type statusJSON struct {
+	Version    int             `json:"version"`
+	Repository string          `json:"repository"`
+	LastUpload *snapshotJSON   `json:"last_upload"`
+	Paths      []pathStateJSON `json:"paths"`
+	Skipped    []skippedJSON   `json:"skipped"`
+}
+
+type fileChangeJSON struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`    // added, modified or deleted
+	Additions *int   `json:"additions"` // null for binary files
+	Deletions *int   `json:"deletions"`
+}
+
+type diffJSON struct {
+	Version  int              `json:"version"`
+	UpToDate bool             `json:"up_to_date"`
+	Files    []fileChangeJSON `json:"files"`
+}
+
+type installedJSON struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Error       string `json:"error,omitempty"`
+}
+
+type installJSON struct {
+	Version    int             `json:"version"`
+	Repository string          `json:"repository"`
+	Installed  []installedJSON `json:"installed"`
+	Failed     []installedJSON `json:"failed"`
+}
+
+type installedFileJSON struct {
+	Path  string `json:"path"`
+	State string `json:"state"` // installed, modified or missing
+}
+
+type installedSourceJSON struct {
+	Source string              `json:"source"`
+	URL    string              `json:"url"`
+	Commit *string             `json:"commit"` // null when the source is not a git repository
+	Time   time.Time           `json:"time"`
+	Files  []installedFileJSON `json:"files"`
+}
+
+type installedListJSON struct {
+	Version int                   `json:"version"`
+	Sources []installedSourceJSON `json:"sources"`
+}
+
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		internal.Exit("Failed to encode output", err)
+	}
+}
+
+func newSnapshotJSON(snapshot internal.Snapshot) snapshotJSON {
+	paths := snapshot.Paths
+	if paths == nil {
+		paths = []string{}
+	}
+	return snapshotJSON{Hash: snapshot.Hash, Date: snapshot.Date, Host: snapshot.Host, Subject: snapshot.Subject, Paths: paths}
+}
+
+func newTrackedJSON(path string, rules internal.IgnoreRules) trackedJSON {
+	tracked := trackedJSON{Path: path, Ignore: []string{}}
+	if internal.IsGlob(path) {
+		matches, _ := internal.ExpandGlob(path)
+		count := len(matches)
+		tracked.Pattern, tracked.Matches = true, &count
+	}
+	if rules != nil {
+		tracked.Ignore = append(tracked.Ignore, rules[path]...)
+	}
+	return tracked
+}
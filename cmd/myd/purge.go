@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+// purgeOptions are the --purge, --rewrite-history and --yes flags of delete and untrack
+type purgeOptions struct {
+	purge          bool
+	rewriteHistory bool
+	yes            bool // rewrite history without asking
+}
+
+// purgeEntries removes the repository copies of untracked paths in a
+// dedicated commit, optionally dropping them from every earlier commit too
+func purgeEntries(config *internal.MydConfig, untracked []string, opts purgeOptions) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		fmt.Println("Nothing uploaded yet, nothing to purge")
+		return
+	}
+
+	entries, err := internal.ReadManifest(repoPath)
+	if err != nil {
+		internal.Exit("Failed to read repository contents", err)
+	}
+
+	var names []string
+	for _, path := range untracked {
+		name := internal.RepoEntryName(path)
+		found := false
+		for _, entry := range entries {
+			if entry.Name != name {
+				continue
+			}
+			found = true
+			if _, err := internal.Git(repoPath, "rm", "-r", "-q", "--ignore-unmatch", "--", name); err != nil {
+				internal.Exit(fmt.Sprintf("Failed to remove %s", name), err)
+			}
+			if !entry.IsDir {
+				if err := removeOriginalPathLine(repoPath, entry.Target); err != nil {
+					internal.Exit("Failed to update .original_path", err)
+				}
+			}
+		}
+		if found {
+			names = append(names, name)
+		} else {
+			fmt.Printf("%s is not in the repository\n", path)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	hasRemote := true
+	if _, err := internal.Git(repoPath, "remote", "get-url", "origin"); err != nil {
+		hasRemote = false
+	}
+
+	if _, err := internal.Git(repoPath, "diff", "--cached", "--quiet"); err != nil {
+		internal.Progress("Removing %s from the repository\n", strings.Join(names, ", "))
+		message := internal.WithHostTrailer(fmt.Sprintf("purge %s", strings.Join(names, ", ")))
+		if err := commitRepo(config, repoPath, message, nil); err != nil {
+			internal.Exit("Failed to commit", err)
+		}
+		if hasRemote {
+			internal.Progress("Pushing changes\n")
+			if _, err := internal.Git(repoPath, "push", "origin", "HEAD"); err != nil {
+				internal.Exit("Failed to push purge", err)
+			}
+		}
+	}
+
+	if !opts.rewriteHistory {
+		fmt.Println("Purge complete! Earlier snapshots still contain the files, use --rewrite-history to drop them")
+		return
+	}
+
+	if !opts.yes {
+		fmt.Printf("This rewrites every snapshot to drop %s", strings.Join(names, ", "))
+		if hasRemote {
+			fmt.Print(" and force pushes the result")
+		}
+		fmt.Print(". Other clones will have to be cloned again. Continue? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			internal.Exit("", fmt.Errorf("history left untouched, the files are still in earlier snapshots"))
+		}
+	}
+
+	if err := rewriteWithout(repoPath, names); err != nil {
+		internal.Exit("Failed to rewrite history", err)
+	}
+	if hasRemote {
+		internal.Progress("Force pushing rewritten history\n")
+		if _, err := internal.Git(repoPath, "push", "--force-with-lease", "origin", "HEAD"); err != nil {
+			internal.Exit("Failed to push rewritten history", err)
+		}
+	}
+	fmt.Println("History rewritten! Files may stay reachable on GitHub through cached views and forks, rotate any secrets they contained")
+}
+
+// rewriteWithout drops names from every commit and expires the old objects
+func rewriteWithout(repoPath string, names []string) error {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+	}
+	filter := "git rm -r -q --cached --ignore-unmatch -- " + strings.Join(quoted, " ")
+
+	internal.Progress("Rewriting history\n")
+	// Only local branches and tags are rewritten, so the remote tracking
+	// branch still guards the force push
+	cmd := exec.Command("git", "filter-branch", "--force", "--index-filter", filter, "--prune-empty", "--", "--branches", "--tags")
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), "FILTER_BRANCH_SQUELCH_WARNING=1")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	refs, err := internal.Git(repoPath, "for-each-ref", "--format=%(refname)", "refs/original/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range strings.Split(refs, "\n") {
+		if ref == "" {
+			continue
+		}
+		if _, err := internal.Git(repoPath, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	if _, err := internal.Git(repoPath, "reflog", "expire", "--expire=now", "--all"); err != nil {
+		return err
+	}
+	_, err = internal.Git(repoPath, "gc", "--prune=now", "--quiet")
+	return err
+}
+
+func removeOriginalPathLine(repoPath, line string) error {
+	originalPathFile := filepath.Join(repoPath, ".original_path")
+	data, err := os.ReadFile(originalPathFile)
+	if err != nil {
+		return nil
+	}
+
+	var kept []string
+	for _, existing := range strings.Split(string(data), "\n") {
+		if existing = strings.TrimSpace(existing); existing != "" && existing != line {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == 0 {
+		_, err := internal.Git(repoPath, "rm", "-q", "--ignore-unmatch", ".original_path")
+		return err
+	}
+	if err := os.WriteFile(originalPathFile, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	_, err = internal.Git(repoPath, "add", ".original_path")
+	return err
+}
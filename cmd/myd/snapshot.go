@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wraient/myd/internal"
+)
+
+// restoreTarget is a manifest entry, or a path inside one, selected for restoring
+type restoreTarget struct {
+	entry internal.ManifestEntry
+	sub   string // path relative to the entry, empty for the whole entry
+}
+
+func (t restoreTarget) repoPath() string {
+	return filepath.Join(t.entry.Name, t.sub)
+}
+
+func (t restoreTarget) dest() string {
+	return filepath.Join(t.entry.Dest(), t.sub)
+}
+
+func handleLog(config *internal.MydConfig, limit int) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	uploaded := true
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		uploaded = false
+	}
+
+	var snapshots []internal.Snapshot
+	if uploaded {
+		var err error
+		if snapshots, err = internal.ListSnapshots(repoPath, limit); err != nil {
+			internal.Exit("Failed to read snapshots", err)
+		}
+	}
+
+	if globals.json {
+		log := logJSON{Version: jsonVersion, Snapshots: []snapshotJSON{}}
+		for _, snapshot := range snapshots {
+			log.Snapshots = append(log.Snapshots, newSnapshotJSON(snapshot))
+		}
+		printJSON(log)
+		return
+	}
+	if !uploaded {
+		internal.Exit("No snapshots yet. Run 'myd upload' first", nil)
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots yet")
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		host := snapshot.Host
+		if host == "" {
+			host = "-"
+		}
+		fmt.Printf("%s  %s  %-16s %s\n", snapshot.Short(), snapshot.Date.Local().Format("2006-01-02 15:04"), host, snapshot.Subject)
+		if len(snapshot.Paths) > 0 {
+			fmt.Printf("         %s\n", strings.Join(snapshot.Paths, ", "))
+		}
+	}
+}
+
+func handleRollback(config *internal.MydConfig, rev string, paths []string, repoOnly bool) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	hash, err := internal.ResolveSnapshot(repoPath, rev)
+	if err != nil {
+		internal.Exit("", err)
+	}
+	short := hash[:7]
+
+	// Check out the snapshot next to the repository so the live tree is untouched
+	worktree := filepath.Join(os.ExpandEnv(config.StoragePath), "temp", "rollback-"+short)
+	os.RemoveAll(worktree)
+	if _, err := internal.Git(repoPath, "worktree", "add", "--detach", worktree, hash); err != nil {
+		internal.Exit("Failed to check out snapshot", err)
+	}
+	defer func() {
+		internal.Git(repoPath, "worktree", "remove", "--force", worktree)
+		os.RemoveAll(worktree)
+	}()
+
+	entries, err := internal.ReadManifest(worktree)
+	if err != nil {
+		internal.Exit("Failed to read snapshot contents", err)
+	}
+	targets, err := selectRestoreTargets(entries, paths)
+	if err != nil {
+		internal.Exit("", err)
+	}
+
+	if repoOnly {
+		rollbackRepo(config, repoPath, hash, targets, len(paths) == 0)
+		return
+	}
+
+	for _, target := range targets {
+		if err := restoreTargetFrom(worktree, target); err != nil {
+			internal.Warn("Failed to restore %s: %v\n", target.dest(), err)
+			continue
+		}
+		fmt.Printf("Restored %s from %s\n", target.dest(), short)
+	}
+}
+
+// selectRestoreTargets matches the requested paths against the snapshot entries
+func selectRestoreTargets(entries []internal.ManifestEntry, paths []string) ([]restoreTarget, error) {
+	if len(paths) == 0 {
+		targets := make([]restoreTarget, 0, len(entries))
+		for _, entry := range entries {
+			targets = append(targets, restoreTarget{entry: entry})
+		}
+		return targets, nil
+	}
+
+	var targets []restoreTarget
+	for _, path := range paths {
+		entry, sub, ok := internal.FindEntry(entries, path)
+		if !ok {
+			return nil, fmt.Errorf("%s is not part of this snapshot", path)
+		}
+		targets = append(targets, restoreTarget{entry: entry, sub: sub})
+	}
+	return targets, nil
+}
+
+// restoreTargetFrom copies a target from a checked out snapshot to its original location
+func restoreTargetFrom(srcDir string, target restoreTarget) error {
+	if target.sub == "" {
+		_, err := installEntry(srcDir, target.entry)
+		return err
+	}
+
+	if _, err := target.entry.InstallDest(); err != nil {
+		return err
+	}
+	srcPath := filepath.Join(srcDir, target.repoPath())
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target.dest()), 0755); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyDir(srcPath, target.dest(), true, nil)
+	}
+	return copyFile(srcPath, target.dest())
+}
+
+// rollbackRepo restores the snapshot inside the repository only and records it
+// as a new commit, leaving the live files alone
+func rollbackRepo(config *internal.MydConfig, repoPath, hash string, targets []restoreTarget, everything bool) {
+	short := hash[:7]
+
+	pathspecs := []string{"."}
+	if !everything {
+		pathspecs = pathspecs[:0]
+		for _, target := range targets {
+			pathspecs = append(pathspecs, target.repoPath())
+		}
+	}
+
+	args := append([]string{"restore", "--source=" + hash, "--staged", "--worktree", "--"}, pathspecs...)
+	if _, err := internal.Git(repoPath, args...); err != nil {
+		internal.Exit("Failed to restore snapshot", err)
+	}
+
+	// Single files also need their line in the root .original_path
+	if !everything {
+		for _, target := range targets {
+			if target.entry.IsDir {
+				continue
+			}
+			if err := ensureOriginalPathLine(repoPath, target.entry.Target); err != nil {
+				internal.Exit("Failed to update .original_path", err)
+			}
+		}
+	}
+
+	if _, err := internal.Git(repoPath, "diff", "--cached", "--quiet"); err == nil {
+		fmt.Printf("Repository already matches %s\n", short)
+		return
+	}
+
+	subject, _ := internal.Git(repoPath, "log", "-1", "--format=%s", hash)
+	message := fmt.Sprintf("rollback to %s\n\nRestored from %q", short, subject)
+	if err := commitRepo(config, repoPath, internal.WithHostTrailer(message), nil); err != nil {
+		internal.Exit("Failed to commit", err)
+	}
+
+	if _, err := internal.Git(repoPath, "remote", "get-url", "origin"); err != nil {
+		fmt.Println("Repository has no remote yet, rollback committed locally")
+		return
+	}
+	internal.Progress("Pushing changes\n")
+	if _, err := internal.Git(repoPath, "push", "origin", "HEAD"); err != nil {
+		internal.Exit("Failed to push rollback", err)
+	}
+	fmt.Printf("Repository rolled back to %s. Live files were not changed and the next upload will snapshot them again\n", short)
+}
+
+func ensureOriginalPathLine(repoPath, line string) error {
+	originalPathFile := filepath.Join(repoPath, ".original_path")
+	data, _ := os.ReadFile(originalPathFile)
+	for _, existing := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return nil
+		}
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(originalPathFile, []byte(content+line+"\n"), 0644); err != nil {
+		return err
+	}
+	_, err := internal.Git(repoPath, "add", ".original_path")
+	return err
+}
+
+func handleHistory(config *internal.MydConfig, path string) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		internal.Exit("No snapshots yet. Run 'myd upload' first", nil)
+	}
+
+	entries, err := internal.ReadManifest(repoPath)
+	if err != nil {
+		internal.Exit("Failed to read repository contents", err)
+	}
+	entry, sub, ok := internal.FindEntry(entries, path)
+	if !ok {
+		internal.Exit(fmt.Sprintf("Error: %s is not part of any uploaded path", path), nil)
+	}
+	target := restoreTarget{entry: entry, sub: sub}
+
+	model, err := internal.NewHistoryModel(repoPath, target.repoPath(), target.dest())
+	if err != nil {
+		internal.Exit("Failed to read history", err)
+	}
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		internal.Exit("Error running history browser", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wraient/myd/internal"
+)
+
+func handleStatus(config *internal.MydConfig) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	if globals.json {
+		printJSON(statusDocument(config, repoPath))
+		return
+	}
+
+	fmt.Printf("Repository: %s\n", remoteName(config))
+	if snapshots, err := internal.ListSnapshots(repoPath, 1); err == nil && len(snapshots) > 0 {
+		last := snapshots[0]
+		fmt.Printf("Last upload: %s (%s %s)\n", last.Date.Local().Format("2006-01-02 15:04"), last.Short(), last.Subject)
+	} else {
+		fmt.Println("Last upload: never")
+	}
+
+	paths := internal.LoadTrackedPaths(config)
+	fmt.Println()
+	if len(paths) == 0 {
+		fmt.Println("No paths are currently being tracked")
+	} else {
+		states := make(map[string]string)
+		if tracked, err := trackedStates(config); err == nil {
+			for _, state := range tracked {
+				states[state.Path] = state.State
+			}
+		}
+
+		fmt.Println("Tracked paths:")
+		for _, path := range paths {
+			state := states[path]
+			if internal.IsGlob(path) {
+				matches, _ := internal.ExpandGlob(path)
+				if state != "" {
+					state = ", " + state
+				}
+				fmt.Printf("  %s (pattern, %d matches%s)\n", path, len(matches), state)
+			} else if state != "" {
+				fmt.Printf("  %s (%s)\n", path, state)
+			} else {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+	}
+
+	skipped := internal.LoadSkipped(config)
+	if len(skipped) > 0 {
+		fmt.Println()
+		fmt.Println("Skipped during the last upload:")
+		for _, file := range skipped {
+			fmt.Printf("  %s (%s, %s)\n", file.Path, internal.FormatSize(file.Size), file.Reason)
+		}
+	}
+}
+
+func statusDocument(config *internal.MydConfig, repoPath string) statusJSON {
+	status := statusJSON{Version: jsonVersion, Repository: remoteName(config), Paths: []pathStateJSON{}, Skipped: []skippedJSON{}}
+	if snapshots, err := internal.ListSnapshots(repoPath, 1); err == nil && len(snapshots) > 0 {
+		last := newSnapshotJSON(snapshots[0])
+		status.LastUpload = &last
+	}
+
+	states := make(map[string]string)
+	if tracked, err := trackedStates(config); err == nil {
+		for _, state := range tracked {
+			states[state.Path] = state.State
+		}
+	} else {
+		internal.Warn("Could not compare with the last upload: %v\n", err)
+	}
+
+	rules, _ := internal.LoadIgnoreRules(config)
+	for _, path := range internal.LoadTrackedPaths(config) {
+		state := states[path]
+		if state == "" {
+			state = "unknown"
+		}
+		status.Paths = append(status.Paths, pathStateJSON{trackedJSON: newTrackedJSON(path, rules), State: state})
+	}
+	for _, file := range internal.LoadSkipped(config) {
+		status.Skipped = append(status.Skipped, skippedJSON{Path: file.Path, Size: file.Size, Reason: file.Reason})
+	}
+	return status
+}
+
+// remoteName returns the owner/name of the upstream repository
+func remoteName(config *internal.MydConfig) string {
+	username := config.Username
+	if data, err := os.ReadFile(filepath.Join(os.ExpandEnv(config.StoragePath), "username")); err == nil && username == "" {
+		username = strings.TrimSpace(string(data))
+	}
+	if username == "" {
+		return config.UpstreamName
+	}
+	return fmt.Sprintf("%s/%s", username, config.UpstreamName)
+}
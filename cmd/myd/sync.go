@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wraient/myd/internal"
+)
+
+// previewUpload copies the live tracked files next to the repository and
+// stages them in a separate index, so they can be compared with the last upload
+func previewUpload(config *internal.MydConfig) (*internal.Preview, error) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return nil, fmt.Errorf("nothing uploaded yet, run 'myd upload' first")
+	}
+
+	workTree := filepath.Join(os.ExpandEnv(config.StoragePath), "temp", "preview")
+	os.RemoveAll(workTree)
+	if err := os.MkdirAll(workTree, 0755); err != nil {
+		return nil, err
+	}
+
+	quiet := internal.Quiet
+	internal.Quiet = true
+	_, err := copyFilesToRepo(config, workTree)
+	internal.Quiet = quiet
+	if err != nil {
+		os.RemoveAll(workTree)
+		return nil, err
+	}
+
+	// Upload leaves the hooks and scripts alone, so they are no change
+	if _, err := os.Stat(filepath.Join(repoPath, ".myd")); err == nil {
+		if err := copyDir(filepath.Join(repoPath, ".myd"), filepath.Join(workTree, ".myd"), false, nil); err != nil {
+			os.RemoveAll(workTree)
+			return nil, err
+		}
+	}
+
+	preview, err := internal.NewPreview(repoPath, workTree)
+	if err != nil {
+		os.RemoveAll(workTree)
+		return nil, err
+	}
+	return preview, nil
+}
+
+// changedEntries returns the top level repository entries touched by changes
+func changedEntries(changes []internal.FileChange) map[string]bool {
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		if path.Base(change.Path) == ".original_path" {
+			continue
+		}
+		changed[strings.SplitN(change.Path, "/", 2)[0]] = true
+	}
+	return changed
+}
+
+// trackedStates compares every tracked path with the last upload
+func trackedStates(config *internal.MydConfig) ([]internal.TrackedState, error) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+
+	uploaded := make(map[string]bool)
+	changed := make(map[string]bool)
+	if names, err := internal.Git(repoPath, "-c", "core.quotePath=false", "ls-tree", "--name-only", "HEAD"); err == nil {
+		for _, name := range strings.Split(names, "\n") {
+			uploaded[name] = true
+		}
+
+		preview, err := previewUpload(config)
+		if err != nil {
+			return nil, err
+		}
+		defer preview.Close()
+
+		changes, err := preview.Changes()
+		if err != nil {
+			return nil, err
+		}
+		changed = changedEntries(changes)
+	}
+
+	var states []internal.TrackedState
+	for _, path := range internal.LoadTrackedPaths(config) {
+		name := internal.RepoEntryName(path)
+		state := internal.StateSynced
+		if _, err := os.Stat(path); err != nil && !internal.IsGlob(path) {
+			state = internal.StateMissing
+		} else if !uploaded[name] {
+			state = internal.StateNew
+		} else if changed[name] {
+			state = internal.StateModified
+		}
+		states = append(states, internal.TrackedState{Path: path, State: state})
+	}
+	return states, nil
+}
+
+func handleDiff(config *internal.MydConfig, paths []string, stat bool) {
+	preview, err := previewUpload(config)
+	if err != nil {
+		internal.Exit("", err)
+	}
+	defer preview.Close()
+
+	// Map live paths to their location inside the repository
+	tracked := internal.LoadTrackedPaths(config)
+	pathspecs := []string{":(exclude,glob)**/.original_path", ":(exclude).original_path"}
+	for _, path := range paths {
+		absPath, err := filepath.Abs(expandHome(path))
+		if err != nil {
+			internal.Exit("Error getting absolute path", err)
+		}
+		trackedPath, ok := internal.ContainingTracked(tracked, absPath)
+		if !ok {
+			preview.Close()
+			internal.Exit("", fmt.Errorf("%s is not tracked", absPath))
+		}
+		rel, _ := filepath.Rel(internal.TrackedRoot(trackedPath), absPath)
+		pathspecs = append(pathspecs, filepath.ToSlash(filepath.Join(internal.RepoEntryName(trackedPath), rel)))
+	}
+
+	if globals.json {
+		files, err := diffFiles(preview, pathspecs)
+		if err != nil {
+			preview.Close()
+			internal.Exit("Failed to compare files", err)
+		}
+		printJSON(diffJSON{Version: jsonVersion, UpToDate: len(files) == 0, Files: files})
+		return
+	}
+
+	if _, err := preview.Git(append([]string{"diff", "--cached", "--quiet", "--"}, pathspecs...)...); err == nil {
+		fmt.Println("Everything up-to-date")
+		return
+	}
+
+	args := []string{"diff", "--cached"}
+	if stat {
+		args = append(args, "--stat")
+	}
+	cmd := preview.Command(append(append(args, "--"), pathspecs...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		preview.Close()
+		internal.Exit("Failed to show diff", err)
+	}
+}
+
+// diffFiles lists the files an upload would change with their line counts
+func diffFiles(preview *internal.Preview, pathspecs []string) ([]fileChangeJSON, error) {
+	diff := func(format string) (string, error) {
+		args := []string{"-c", "core.quotePath=false", "diff", "--cached", "--no-renames", format, "--"}
+		return preview.Git(append(args, pathspecs...)...)
+	}
+	names, err := diff("--name-status")
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := diff("--numstat")
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string][2]*int)
+	for _, line := range strings.Split(numstat, "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		// Binary files have - instead of line counts
+		var additions, deletions *int
+		if added, err := strconv.Atoi(parts[0]); err == nil {
+			additions = &added
+		}
+		if deleted, err := strconv.Atoi(parts[1]); err == nil {
+			deletions = &deleted
+		}
+		counts[parts[2]] = [2]*int{additions, deletions}
+	}
+
+	files := []fileChangeJSON{}
+	for _, change := range internal.ParseNameStatus(names) {
+		count := counts[change.Path]
+		files = append(files, fileChangeJSON{Path: change.Path, Status: change.Status, Additions: count[0], Deletions: count[1]})
+	}
+	return files, nil
+}
+
+func handlePull(config *internal.MydConfig, force bool) {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	if _, err := internal.Git(repoPath, "remote", "get-url", "origin"); err != nil {
+		internal.Exit("Nothing to pull from, run 'myd upload' first", nil)
+	}
+
+	internal.Progress("Fetching changes\n")
+	if _, err := internal.Git(repoPath, "fetch", "origin"); err != nil {
+		internal.Exit("Failed to fetch", err)
+	}
+	names, err := internal.Git(repoPath, "-c", "core.quotePath=false", "diff", "--name-status", "--no-renames", "HEAD", "@{upstream}")
+	if err != nil {
+		internal.Exit("Failed to compare with upstream", err)
+	}
+	if names == "" {
+		fmt.Println("Already up to date")
+		return
+	}
+	incoming := changedEntries(internal.ParseNameStatus(names))
+
+	// Refuse to overwrite live files that changed since the last upload
+	if !force {
+		preview, err := previewUpload(config)
+		if err != nil {
+			internal.Exit("", err)
+		}
+		changes, err := preview.Changes()
+		preview.Close()
+		if err != nil {
+			internal.Exit("Failed to check local changes", err)
+		}
+
+		var conflicts []string
+		for name := range changedEntries(changes) {
+			if incoming[name] {
+				conflicts = append(conflicts, name)
+			}
+		}
+		if len(conflicts) > 0 {
+			internal.Exit("", fmt.Errorf("local changes to %s would be overwritten, upload them first or pull with --force", strings.Join(conflicts, ", ")))
+		}
+	}
+
+	if _, err := internal.Git(repoPath, "merge", "--ff-only", "@{upstream}"); err != nil {
+		internal.Exit("Failed to fast-forward, the local repository has diverged", err)
+	}
+
+	entries, err := internal.ReadManifest(repoPath)
+	if err != nil {
+		internal.Exit("Failed to read repository contents", err)
+	}
+	for _, entry := range entries {
+		if !incoming[entry.Name] {
+			continue
+		}
+		destPath, err := installEntry(repoPath, entry)
+		if err != nil {
+			internal.Warn("Failed to install %s: %v\n", entry.Name, err)
+			continue
+		}
+		fmt.Printf("Installed %s\n", destPath)
+	}
+	fmt.Println("Pull complete!")
+}
+
+func handleDashboard(config *internal.MydConfig) {
+	myd, err := os.Executable()
+	if err != nil {
+		internal.Exit("Failed to locate the myd executable", err)
+	}
+
+	load := func() internal.DashboardState {
+		repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+		state := internal.DashboardState{Remote: remoteName(config)}
+		if snapshots, err := internal.ListSnapshots(repoPath, 1); err == nil && len(snapshots) > 0 {
+			state.LastUpload = &snapshots[0]
+		}
+		state.Paths, state.Err = trackedStates(config)
+		return state
+	}
+
+	p := tea.NewProgram(internal.NewDashboardModel(load, myd, "--config", globals.configPath), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		internal.Exit("Error running dashboard", err)
+	}
+}
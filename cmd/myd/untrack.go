@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wraient/myd/internal"
+)
+
+// exitNotTracked is the exit code of untrack when a path is not tracked
+const exitNotTracked = 2
+
+// usageError reports wrong arguments on stderr and exits with 2, like the
+// argument checks of every command
+func usageError(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", a...)
+	os.Exit(2)
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// handleUntrack removes paths and patterns from toupload.txt. A pattern also
+// removes every tracked path it matches. Nothing is changed unless every
+// argument is tracked.
+func handleUntrack(config *internal.MydConfig, args []string, opts purgeOptions) {
+	if opts.rewriteHistory && !opts.purge {
+		usageError("--rewrite-history requires --purge")
+	}
+	// The confirmation cannot be read from a pipe or after the paths
+	if opts.rewriteHistory && !opts.yes && (!stdinIsTerminal() || readsStdin(args)) {
+		usageError("--rewrite-history needs --yes when stdin is not a terminal")
+	}
+	paths := pathArgs(args)
+	if len(paths) == 0 {
+		usageError("path required for untrack command")
+	}
+
+	tracked := internal.LoadTrackedPaths(config)
+	remove := make(map[string]bool)
+	missing := 0
+	for _, path := range paths {
+		absPath, err := filepath.Abs(expandHome(path))
+		if err != nil {
+			internal.Exit("Error getting absolute path", err)
+		}
+
+		found := false
+		for _, trackedPath := range tracked {
+			if trackedPath == absPath || (internal.IsGlob(absPath) && internal.MatchGlob(absPath, trackedPath)) {
+				remove[trackedPath] = true
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+
+		missing++
+		if parent, ok := internal.ContainingTracked(tracked, absPath); ok && !internal.IsGlob(absPath) {
+			fmt.Fprintf(os.Stderr, "Error: %s is not tracked itself, it is part of %s. Use 'myd ignore' to leave it out\n", absPath, parent)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %s is not tracked\n", absPath)
+		}
+	}
+	if missing > 0 {
+		fmt.Fprintln(os.Stderr, "Nothing was untracked")
+		os.Exit(exitNotTracked)
+	}
+
+	var kept, removed []string
+	for _, trackedPath := range tracked {
+		if remove[trackedPath] {
+			removed = append(removed, trackedPath)
+		} else {
+			kept = append(kept, trackedPath)
+		}
+	}
+	if err := internal.SaveTrackedPaths(config, kept); err != nil {
+		internal.Exit("Error writing to toupload.txt", err)
+	}
+	for _, path := range removed {
+		fmt.Printf("Removed %s from upload list\n", path)
+	}
+
+	if opts.purge {
+		purgeEntries(config, removed, opts)
+	}
+}
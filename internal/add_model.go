@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pathEstimate is the size and file count a selected path adds to uploads
+type pathEstimate struct {
+	size  int64
+	files int
+	done  bool
+}
+
+type estimateMsg struct {
+	path     string
+	estimate pathEstimate
+}
+
+type addEntry struct {
+	name  string
+	path  string
+	isDir bool
+}
+
+// AddModel is a file browser for picking paths to track
+type AddModel struct {
+	config    *MydConfig
+	tracked   []string
+	dir       string
+	entries   []addEntry
+	selected  map[string]bool
+	order     []string // selected paths in the order they were picked
+	estimates map[string]pathEstimate
+
+	cursor     int
+	offset     int
+	height     int
+	confirming bool
+	status     string
+	saved      []string
+	quitting   bool
+}
+
+// NewAddModel opens the browser in start, usually $HOME
+func NewAddModel(config *MydConfig, start string) (*AddModel, error) {
+	m := &AddModel{
+		config:    config,
+		tracked:   LoadTrackedPaths(config),
+		selected:  make(map[string]bool),
+		estimates: make(map[string]pathEstimate),
+		height:    24,
+	}
+	return m, m.open(start)
+}
+
+// Saved returns the paths that were added when the browser closed
+func (m *AddModel) Saved() []string {
+	return m.saved
+}
+
+func (m *AddModel) open(dir string) error {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]addEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		path := filepath.Join(dir, entry.Name())
+		isDir := entry.IsDir()
+		// Follow symlinks to directories so they can be browsed too
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if info, err := os.Stat(path); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		entries = append(entries, addEntry{name: entry.Name(), path: path, isDir: isDir})
+	}
+
+	// Directories first, then files, each alphabetically
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].isDir != entries[j].isDir {
+			return entries[i].isDir
+		}
+		return strings.ToLower(entries[i].name) < strings.ToLower(entries[j].name)
+	})
+
+	m.dir = dir
+	m.entries = entries
+	m.cursor, m.offset = 0, 0
+	return nil
+}
+
+// trackState describes how a path relates to the tracked list
+func (m *AddModel) trackState(path string) string {
+	for _, tracked := range m.tracked {
+		if tracked == path {
+			return "tracked"
+		}
+	}
+	if parent, ok := ContainingTracked(m.tracked, path); ok && parent != path {
+		return "inside tracked"
+	}
+	return ""
+}
+
+func estimatePath(path string) tea.Cmd {
+	return func() tea.Msg {
+		estimate := pathEstimate{done: true}
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Keep counting past unreadable directories
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				estimate.size += info.Size()
+				estimate.files++
+			}
+			return nil
+		})
+		return estimateMsg{path: path, estimate: estimate}
+	}
+}
+
+func (m *AddModel) toggle(path string) tea.Cmd {
+	if m.selected[path] {
+		delete(m.selected, path)
+		for i, selected := range m.order {
+			if selected == path {
+				m.order = append(m.order[:i], m.order[i+1:]...)
+				break
+			}
+		}
+		return nil
+	}
+
+	if state := m.trackState(path); state != "" {
+		m.status = fmt.Sprintf("%s is already %s", path, state)
+		return nil
+	}
+	m.selected[path] = true
+	m.order = append(m.order, path)
+	if _, ok := m.estimates[path]; !ok {
+		m.estimates[path] = pathEstimate{}
+		return estimatePath(path)
+	}
+	return nil
+}
+
+func (m *AddModel) save() tea.Cmd {
+	if err := AppendTrackedPaths(m.config, m.order); err != nil {
+		m.status = fmt.Sprintf("Failed to save: %v", err)
+		m.confirming = false
+		return nil
+	}
+	m.saved = m.order
+	m.quitting = true
+	return tea.Quit
+}
+
+func (m *AddModel) listHeight() int {
+	return max(m.height-8-min(len(m.order), 6), 3)
+}
+
+func (m *AddModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *AddModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case estimateMsg:
+		m.estimates[msg.path] = msg.estimate
+	case tea.KeyMsg:
+		if m.confirming {
+			switch msg.String() {
+			case "y", "enter":
+				return m, m.save()
+			case "n", "esc":
+				m.confirming = false
+			case "ctrl+c", "q":
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		m.status = ""
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.entries)-1 {
+				m.cursor++
+			}
+		case "right", "l", "enter":
+			if len(m.entries) > 0 && m.entries[m.cursor].isDir {
+				if err := m.open(m.entries[m.cursor].path); err != nil {
+					m.status = err.Error()
+				}
+			}
+		case "left", "h", "backspace":
+			previous := m.dir
+			if err := m.open(filepath.Dir(m.dir)); err != nil {
+				m.status = err.Error()
+				break
+			}
+			for i, entry := range m.entries {
+				if entry.path == previous {
+					m.cursor = i
+				}
+			}
+		case " ":
+			if len(m.entries) > 0 {
+				return m, m.toggle(m.entries[m.cursor].path)
+			}
+		case "s":
+			if len(m.order) > 0 {
+				m.confirming = true
+			} else {
+				m.status = "Nothing selected"
+			}
+		}
+
+		// Keep the cursor inside the viewport
+		if m.cursor < m.offset {
+			m.offset = m.cursor
+		} else if m.cursor >= m.offset+m.listHeight() {
+			m.offset = m.cursor - m.listHeight() + 1
+		}
+	}
+	return m, nil
+}
+
+func (m *AddModel) estimateText(path string) string {
+	estimate := m.estimates[path]
+	if !estimate.done {
+		return "counting..."
+	}
+	noun := "files"
+	if estimate.files == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%s, %d %s", FormatSize(estimate.size), estimate.files, noun)
+}
+
+func (m *AddModel) totals() (int64, int, bool) {
+	var size int64
+	files, done := 0, true
+	for _, path := range m.order {
+		estimate := m.estimates[path]
+		size += estimate.size
+		files += estimate.files
+		done = done && estimate.done
+	}
+	return size, files, done
+}
+
+func (m *AddModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	faintStyle := lipgloss.NewStyle().Faint(true)
+
+	if m.confirming {
+		s := "Add these paths to the upload list?\n\n"
+		for _, path := range m.order {
+			s += fmt.Sprintf("  %s  %s\n", path, faintStyle.Render(m.estimateText(path)))
+		}
+		size, files, done := m.totals()
+		total := fmt.Sprintf("%s in %d files", FormatSize(size), files)
+		if !done {
+			total += " so far"
+		}
+		s += fmt.Sprintf("\nTotal: %s\n\nPress y to save, n to go back\n", total)
+		return s
+	}
+
+	s := fmt.Sprintf("%s (space to select, enter to open, s to save):\n\n", m.dir)
+	if len(m.entries) == 0 {
+		s += "  (empty)\n"
+	}
+
+	end := min(m.offset+m.listHeight(), len(m.entries))
+	for i := m.offset; i < end; i++ {
+		entry := m.entries[i]
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+
+		checked := " "
+		if m.selected[entry.path] {
+			checked = "x"
+		}
+
+		name := entry.name
+		if entry.isDir {
+			name += "/"
+		}
+
+		line := fmt.Sprintf("%s [%s] %s", cursor, checked, name)
+		note := ""
+		if state := m.trackState(entry.path); state != "" {
+			note = "  " + faintStyle.Render("("+state+")")
+		} else if m.selected[entry.path] {
+			note = "  " + faintStyle.Render(m.estimateText(entry.path))
+		}
+
+		if m.cursor == i {
+			s += selectedStyle.Render(line) + note + "\n"
+		} else {
+			s += line + note + "\n"
+		}
+	}
+
+	if len(m.order) > 0 {
+		size, files, _ := m.totals()
+		s += fmt.Sprintf("\n%d selected, %s in %d files\n", len(m.order), FormatSize(size), files)
+		for i, path := range m.order {
+			if i == 5 {
+				s += faintStyle.Render(fmt.Sprintf("  ... and %d more", len(m.order)-5)) + "\n"
+				break
+			}
+			s += fmt.Sprintf("  %s  %s\n", path, faintStyle.Render(m.estimateText(path)))
+		}
+	}
+
+	if m.status != "" {
+		s += "\n" + style.Render(m.status) + "\n"
+	}
+	s += "\nPress q to quit\n"
+	return s
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive reports whether path looks like an archive ExtractArchive reads
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".zip")
+}
+
+// ExtractArchive unpacks a .tar.gz, .tgz or .zip file into dest, refusing
+// entries and links that would end up outside of it
+func ExtractArchive(src, dest string) error {
+	if strings.HasSuffix(strings.ToLower(src), ".zip") {
+		return extractZip(src, dest)
+	}
+	return extractTarGz(src, dest)
+}
+
+// archivePath joins an archive entry name to dest, failing when the entry
+// escapes dest through an absolute path, .. or a link extracted earlier
+func archivePath(dest, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("archive entry %s is an absolute path", name)
+	}
+	dest = filepath.Clean(dest)
+	path := filepath.Join(dest, name)
+	if path != dest && !strings.HasPrefix(path, dest+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %s points outside the archive", name)
+	}
+
+	// Links are only checked as text when they are created, a later entry
+	// going through one could still leave dest
+	rel, _ := filepath.Rel(dest, path)
+	current := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			break
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %s goes through the link %s", name, current)
+		}
+	}
+	return path, nil
+}
+
+// archiveLink creates a symlink, as long as it resolves inside dest
+func archiveLink(dest, path, target string) error {
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("link %s points to the absolute path %s", path, target)
+	}
+	rel, err := filepath.Rel(dest, filepath.Join(filepath.Dir(path), target))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("link %s points outside the archive", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func writeArchiveFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func extractTarGz(src, dest string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a gzip archive: %v", src, err)
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := archivePath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0755)
+		case tar.TypeReg:
+			err = writeArchiveFile(path, reader, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = archiveLink(dest, path, header.Linkname)
+		default:
+			// GitHub exports carry a pax header with the commit, devices and
+			// hard links have no place in dotfiles
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(src, dest string) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("%s is not a zip archive: %v", src, err)
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		path, err := archivePath(dest, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			var target []byte
+			if target, err = io.ReadAll(r); err == nil {
+				err = archiveLink(dest, path, string(target))
+			}
+		} else {
+			err = writeArchiveFile(path, r, mode)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
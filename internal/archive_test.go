@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// archiveEntry is a file, directory or symlink written into a test archive
+type archiveEntry struct {
+	name string
+	body string // file contents, empty for directories and links
+	link string // symlink target
+	dir  bool
+}
+
+func writeTestTarGz(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.body)), Typeflag: tar.TypeReg}
+		switch {
+		case entry.dir:
+			header.Typeflag, header.Mode, header.Size = tar.TypeDir, 0755, 0
+		case entry.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name}
+		body := entry.body
+		switch {
+		case entry.dir:
+			header.Name += "/"
+			header.SetMode(os.ModeDir | 0755)
+		case entry.link != "":
+			header.SetMode(os.ModeSymlink | 0777)
+			body = entry.link
+		default:
+			header.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		wantErr bool
+		want    []string // files expected inside dest
+	}{
+		{
+			name: "plain files",
+			entries: []archiveEntry{
+				{name: "dots", dir: true},
+				{name: "dots/.bashrc", body: "export A=1"},
+				{name: "dots/nvim/init.lua", body: "-- lua"},
+			},
+			want: []string{"dots/.bashrc", "dots/nvim/init.lua"},
+		},
+		{
+			name:    "link inside the archive",
+			entries: []archiveEntry{{name: "a/file", body: "x"}, {name: "a/link", link: "file"}},
+			want:    []string{"a/file", "a/link"},
+		},
+		{name: "absolute path", entries: []archiveEntry{{name: "/etc/evil", body: "x"}}, wantErr: true},
+		{name: "dot dot", entries: []archiveEntry{{name: "../escaped.txt", body: "x"}}, wantErr: true},
+		{name: "nested dot dot", entries: []archiveEntry{{name: "a/../../escaped.txt", body: "x"}}, wantErr: true},
+		{name: "absolute link", entries: []archiveEntry{{name: "a/link", link: "/etc"}}, wantErr: true},
+		{name: "link leaving the archive", entries: []archiveEntry{{name: "a/link", link: "../.."}}, wantErr: true},
+		{
+			name: "file through a link",
+			entries: []archiveEntry{
+				{name: "a/b", link: "."},
+				{name: "a/b/escaped.txt", body: "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "chained links",
+			entries: []archiveEntry{
+				{name: "a/b", link: ".."},
+				{name: "a/b/c", link: ".."},
+				{name: "a/b/c/escaped.txt", body: "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "file over a link",
+			entries: []archiveEntry{
+				{name: "link", link: "a"},
+				{name: "link", body: "x"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, format := range []string{"tar.gz", "zip"} {
+		for _, test := range tests {
+			t.Run(format+"/"+test.name, func(t *testing.T) {
+				// dest sits two levels down so escapes have somewhere to land
+				root := t.TempDir()
+				dest := filepath.Join(root, "x", "dest")
+				if err := os.MkdirAll(dest, 0755); err != nil {
+					t.Fatal(err)
+				}
+				archive := filepath.Join(root, "dots."+format)
+				if format == "zip" {
+					writeTestZip(t, archive, test.entries)
+				} else {
+					writeTestTarGz(t, archive, test.entries)
+				}
+
+				err := ExtractArchive(archive, dest)
+				if test.wantErr != (err != nil) {
+					t.Fatalf("ExtractArchive() error = %v, want error %v", err, test.wantErr)
+				}
+				for _, escaped := range []string{filepath.Join(root, "escaped.txt"), filepath.Join(root, "x", "escaped.txt")} {
+					if _, err := os.Lstat(escaped); err == nil {
+						t.Fatalf("%s was written outside the destination", escaped)
+					}
+				}
+				for _, want := range test.want {
+					if _, err := os.Lstat(filepath.Join(dest, want)); err != nil {
+						t.Errorf("%s was not extracted: %v", want, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for path, want := range map[string]bool{
+		"dots.tar.gz": true,
+		"dots.TGZ":    true,
+		"dots.zip":    true,
+		"dots.tar":    false,
+		"dots":        false,
+	} {
+		if got := IsArchive(path); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultCommitTemplate is used when the config does not set commit.template
+const DefaultCommitTemplate = "{{.Subject}}\n\n{{.Body}}"
+
+// HostTrailer is the commit trailer recording the machine that made a snapshot
+const HostTrailer = "Myd-Host"
+
+// FileChange is a single staged change inside the dotfiles repository
+type FileChange struct {
+	Status string // "added", "modified" or "deleted"
+	Path   string // path relative to the repository root
+}
+
+// ChangeGroup collects the changes that belong to one tracked path
+type ChangeGroup struct {
+	Name     string // top level entry in the repository
+	Original string // original location as stored in .original_path
+	Action   string // "add", "update" or "remove"
+	Changes  []FileChange
+}
+
+// CommitData is the data passed to the commit message template
+type CommitData struct {
+	Subject  string
+	Body     string
+	Date     string
+	Host     string
+	Groups   []ChangeGroup
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// StagedChanges lists the changes currently staged in the repository
+func StagedChanges(repoPath string) ([]FileChange, error) {
+	output, err := Git(repoPath, "-c", "core.quotePath=false", "diff", "--cached", "--name-status", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameStatus(output), nil
+}
+
+// ParseNameStatus parses the output of git diff --name-status
+func ParseNameStatus(output string) []FileChange {
+	var changes []FileChange
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		status := "modified"
+		switch parts[0] {
+		case "A":
+			status = "added"
+		case "D":
+			status = "deleted"
+		}
+		changes = append(changes, FileChange{Status: status, Path: parts[1]})
+	}
+	return changes
+}
+
+// GroupChanges groups changes by the tracked path they belong to.
+// Bookkeeping files such as .original_path are left out of the result.
+func GroupChanges(repoPath string, changes []FileChange) []ChangeGroup {
+	groups := make(map[string]*ChangeGroup)
+	var order []string
+
+	for _, change := range changes {
+		if filepath.Base(change.Path) == ".original_path" {
+			continue
+		}
+
+		name := strings.SplitN(change.Path, "/", 2)[0]
+		group, ok := groups[name]
+		if !ok {
+			group = &ChangeGroup{Name: name, Original: originalPathOf(repoPath, name)}
+			groups[name] = group
+			order = append(order, name)
+		}
+		group.Changes = append(group.Changes, change)
+	}
+
+	sort.Strings(order)
+	result := make([]ChangeGroup, 0, len(order))
+	for _, name := range order {
+		group := groups[name]
+		group.Action = groupAction(repoPath, group)
+		result = append(result, *group)
+	}
+	return result
+}
+
+// groupAction decides whether a tracked path was added, removed or updated
+func groupAction(repoPath string, group *ChangeGroup) string {
+	allAdded, allDeleted := true, true
+	for _, change := range group.Changes {
+		allAdded = allAdded && change.Status == "added"
+		allDeleted = allDeleted && change.Status == "deleted"
+	}
+
+	if allDeleted {
+		if _, err := os.Stat(filepath.Join(repoPath, group.Name)); os.IsNotExist(err) {
+			return "remove"
+		}
+	}
+	if allAdded {
+		if _, err := Git(repoPath, "cat-file", "-e", "HEAD:"+group.Name); err != nil {
+			return "add"
+		}
+	}
+	return "update"
+}
+
+// originalPathOf looks up the original location of a repository entry
+func originalPathOf(repoPath, name string) string {
+	if data, err := os.ReadFile(filepath.Join(repoPath, name, ".original_path")); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	// Single files are listed in the root .original_path
+	if data, err := os.ReadFile(filepath.Join(repoPath, ".original_path")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && filepath.Base(line) == name {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// commitSubject builds a subject like "update nvim, kitty; add tmux"
+func commitSubject(groups []ChangeGroup) string {
+	byAction := make(map[string][]string)
+	for _, group := range groups {
+		byAction[group.Action] = append(byAction[group.Action], group.Name)
+	}
+
+	var parts, short []string
+	for _, action := range []string{"update", "add", "remove"} {
+		names := byAction[action]
+		if len(names) == 0 {
+			continue
+		}
+		parts = append(parts, action+" "+strings.Join(names, ", "))
+
+		noun := "paths"
+		if len(names) == 1 {
+			noun = "path"
+		}
+		short = append(short, fmt.Sprintf("%s %d %s", action, len(names), noun))
+	}
+
+	if len(parts) == 0 {
+		return "update metadata"
+	}
+
+	subject := strings.Join(parts, "; ")
+	if len(subject) > 72 {
+		subject = strings.Join(short, "; ")
+	}
+	return subject
+}
+
+// commitBody lists the changed files grouped by tracked path
+func commitBody(groups []ChangeGroup) string {
+	var b strings.Builder
+	for i, group := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		header := group.Original
+		if header == "" {
+			header = group.Name
+		}
+		b.WriteString(header + "\n")
+
+		for _, change := range group.Changes {
+			rel := strings.TrimPrefix(strings.TrimPrefix(change.Path, group.Name), "/")
+			if rel == "" {
+				rel = group.Name
+			}
+			fmt.Fprintf(&b, "  %s: %s\n", change.Status, rel)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// BuildCommitMessage renders the commit message for the staged changes.
+// A non-empty override replaces the generated message.
+func BuildCommitMessage(repoPath string, changes []FileChange, tmpl string, override string) (string, error) {
+	if override != "" {
+		return WithHostTrailer(override), nil
+	}
+
+	groups := GroupChanges(repoPath, changes)
+	data := CommitData{
+		Subject: commitSubject(groups),
+		Body:    commitBody(groups),
+		Date:    time.Now().Format("2006-01-02 15:04:05"),
+		Groups:  groups,
+	}
+	data.Host, _ = os.Hostname()
+	for _, change := range changes {
+		switch change.Status {
+		case "added":
+			data.Added = append(data.Added, change.Path)
+		case "deleted":
+			data.Deleted = append(data.Deleted, change.Path)
+		default:
+			data.Modified = append(data.Modified, change.Path)
+		}
+	}
+
+	if tmpl == "" {
+		tmpl = DefaultCommitTemplate
+	}
+	// Old single line configs wrote newlines as a literal \n
+	tmpl = strings.ReplaceAll(tmpl, `\n`, "\n")
+
+	t, err := template.New("commit").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid commit template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render commit template: %v", err)
+	}
+
+	message := strings.TrimSpace(buf.String())
+	if message == "" {
+		message = data.Subject
+	}
+	return WithHostTrailer(message), nil
+}
+
+// WithHostTrailer appends the Myd-Host trailer so snapshots can be traced to a machine
+func WithHostTrailer(message string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return message
+	}
+	return fmt.Sprintf("%s\n\n%s: %s", strings.TrimSpace(message), HostTrailer, host)
+}
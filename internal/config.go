@@ -1,29 +1,169 @@
 package internal
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// MydConfig struct with field names that match the config keys
+// MydConfig struct with field names that match the config keys. The part of
+// a key before the last dot is its [section] in config.toml.
 type MydConfig struct {
-	StoragePath             string `config:"StoragePath"`
-	UpstreamName            string `config:"UpstreamName"`
-	Username                string `config:"Username"`
+	StoragePath       string        `config:"storage_path"`
+	Profile           string        `config:"profile"`
+	UpstreamName      string        `config:"upstream.name"`
+	Username          string        `config:"upstream.username"`
+	CommitTemplate    string        `config:"commit.template"`
+	CommitIdentity    string        `config:"commit.identity"`
+	CommitName        string        `config:"commit.name"`
+	CommitEmail       string        `config:"commit.email"`
+	Signing           SigningConfig `config:"signing"`
+	MaxFileSizeMB     int           `config:"files.max_size_mb"`
+	DetectBinaryFiles bool          `config:"files.detect_binary"`
+	UseGitLFS         bool          `config:"files.use_lfs"`
+	IgnorePatterns    []string      `config:"ignore.patterns"`
+	PathRoots         []string      `config:"paths.roots"`
+	Hooks             HooksConfig   `config:"hooks"`
 }
 
-// Default configuration values as a map
-func defaultConfigMap() map[string]string {
-	return map[string]string{
-		"StoragePath":             "$HOME/.local/share/myd",
-		"UpstreamName":            "dotfilestest",
-		"Username":                "",
+// SigningConfig is the [signing] section
+type SigningConfig struct {
+	Enabled bool   `config:"enabled"`
+	Format  string `config:"format"`
+	Key     string `config:"key"`
+}
+
+// HooksConfig is the [hooks] section, shell commands run around upload and
+// install next to the hooks in the repository
+type HooksConfig struct {
+	PreUpload   []string      `config:"pre_upload"`
+	PostUpload  []string      `config:"post_upload"`
+	PreInstall  []string      `config:"pre_install"`
+	PostInstall []string      `config:"post_install"`
+	Timeout     time.Duration `config:"timeout"`
+}
+
+// configChoices lists the allowed values of keys that take one of a few words
+var configChoices = map[string][]string{
+	"commit.identity": {"auto", "config", "git", "github"},
+	"signing.format":  {"gpg", "openpgp", "ssh"},
+}
+
+// ConfigError is a problem with the config file, pointing at the line
+// that caused it when there is one
+type ConfigError struct {
+	Path string
+	Line int // 0 when the key is missing from the file
+	Key  string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	var parts []string
+	if e.Path != "" && e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("%s:%d", e.Path, e.Line))
+	} else if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	if e.Key != "" {
+		parts = append(parts, e.Key)
 	}
+	return strings.Join(append(parts, e.Err.Error()), ": ")
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// defaultConfig is written for new installs and supplies missing keys
+const defaultConfig = `# myd configuration
+
+# Where the repository and tracked paths are kept, empty uses
+# $XDG_DATA_HOME/myd or ~/.local/share/myd
+storage_path = ""
+# Name of a [profiles.<name>] section whose keys override the ones below
+profile = ""
+
+[upstream]
+# GitHub repository the dotfiles are uploaded to
+name = "dotfilestest"
+username = ""
+
+[commit]
+# Go template for upload messages, see the README for the fields
+template = "{{.Subject}}\n\n{{.Body}}"
+# auto, config, git or github
+identity = "auto"
+name = ""
+email = ""
+
+[signing]
+enabled = false
+# gpg or ssh
+format = "gpg"
+key = ""
+
+[files]
+# Larger files are skipped or stored with Git LFS, 0 disables the check
+max_size_mb = 50
+detect_binary = false
+use_lfs = false
+
+[ignore]
+# Patterns ignored under every tracked path
+patterns = []
+
+[paths]
+# Extra placeholders for uploaded paths, written as NAME=PATH, e.g.
+# ["WORK=/srv/work"]. Install only writes below these, $HOME,
+# $XDG_CONFIG_HOME and $XDG_DATA_HOME
+roots = []
+
+[hooks]
+# Shell commands run before and after upload and install, after the
+# hooks in the repository's .myd/hooks, see the README
+pre_upload = []
+post_upload = []
+pre_install = []
+post_install = []
+# Hooks and scripts still running after this long are stopped, "0s" waits forever
+timeout = "5m"
+
+# [profiles.work]
+# upstream.name = "work-dotfiles"
+# commit.email = "me@work.example"
+`
+
+// xdgDir returns the XDG base directory in env, or fallback under the home
+// directory when it is unset or relative, as the spec requires
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), fallback)
+}
+
+// DefaultConfigPath returns $MYD_CONFIG, or config.toml in the XDG config directory
+func DefaultConfigPath() string {
+	if path := os.Getenv("MYD_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "myd", "config.toml")
+}
+
+// DefaultStoragePath is used when storage_path is empty
+func DefaultStoragePath() string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "myd")
+}
+
+// envName returns the variable that overrides a config key, e.g.
+// MYD_UPSTREAM_NAME for upstream.name
+func envName(key string) string {
+	return "MYD_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
 }
 
 var globalConfig *MydConfig
@@ -36,90 +176,108 @@ func GetGlobalConfig() *MydConfig {
 	return globalConfig
 }
 
-// LoadConfig reads or creates the config file, adds missing fields, and returns the populated CurdConfig struct
+// LoadConfig reads or creates the config file, adds missing fields, and returns the populated MydConfig struct
 func LoadConfig(configPath string) (MydConfig, error) {
 	configPath = os.ExpandEnv(configPath) // Substitute environment variables like $HOME
 
-	// Check if config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Create the config file with default values if it doesn't exist
-		fmt.Println("Config file not found. Creating default config...")
-		if err := createDefaultConfig(configPath); err != nil {
-			return MydConfig{}, fmt.Errorf("error creating default config file: %v", err)
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		data, err = createDefaultConfig(configPath)
+	}
+	if err != nil {
+		return MydConfig{}, fmt.Errorf("error creating default config file: %v", err)
+	}
+
+	// Configs from before config.toml are converted in place
+	if legacy, ok := parseLegacyConfig(string(data)); ok {
+		Progress("Converting %s to the new config format, the old file is kept as %s.old\n", configPath, configPath)
+		if err := os.WriteFile(configPath+".old", data, 0644); err != nil {
+			return MydConfig{}, fmt.Errorf("error backing up config file: %v", err)
+		}
+		if data, err = migrateLegacyConfig(configPath, legacy); err != nil {
+			return MydConfig{}, fmt.Errorf("error converting config file: %v", err)
 		}
 	}
 
-	// Load the config from file
-	configMap, err := loadConfigFromFile(configPath)
+	doc, err := parseConfig(configPath, data)
 	if err != nil {
-		return MydConfig{}, fmt.Errorf("error loading config file: %v", err)
+		return MydConfig{}, err
 	}
 
-	// Add missing fields to the config map
+	// Add missing fields, leaving the rest of the file untouched
+	defaults, _ := parseTOML(defaultConfig)
 	updated := false
-	defaultConfigMap := defaultConfigMap()
-	for key, defaultValue := range defaultConfigMap {
-		if _, exists := configMap[key]; !exists {
-			configMap[key] = defaultValue
+	for _, key := range ConfigKeys() {
+		section, name := splitKey(key)
+		if doc.get(section, name) == nil {
+			doc.set(section, name, defaults.get(section, name).value)
 			updated = true
 		}
 	}
 
 	// Write updated config back to file if there were any missing fields
 	if updated {
-		if err := saveConfigToFile(configPath, configMap); err != nil {
+		if err := os.WriteFile(configPath, []byte(doc.String()), 0644); err != nil {
 			return MydConfig{}, fmt.Errorf("error saving updated config file: %v", err)
 		}
 	}
 
-	// Populate the CurdConfig struct from the config map
-	config := populateConfig(configMap)
-
-	return config, nil
+	return populateConfig(configPath, doc)
 }
 
-// Create a config file with default values in key=value format
-// Ensure the directory exists before creating the file
-func createDefaultConfig(path string) error {
-	defaultConfig := defaultConfigMap()
+func parseConfig(path string, data []byte) (*tomlDoc, error) {
+	doc, err := parseTOML(string(data))
+	if configErr, ok := err.(*ConfigError); ok {
+		configErr.Path = path
+	}
+	return doc, err
+}
 
+// Create a config file with default values, or with the values of a legacy
+// key=value config next to it
+func createDefaultConfig(path string) ([]byte, error) {
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("error creating directory: %v", err)
+		return nil, fmt.Errorf("error creating directory: %v", err)
 	}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
+	legacyPaths := []string{filepath.Join(dir, "config")}
+	if os.Getenv("MYD_CONFIG") == "" && path == DefaultConfigPath() {
+		// Older versions always used ~/.config/myd/config
+		legacyPaths = append(legacyPaths, filepath.Join(os.Getenv("HOME"), ".config", "myd", "config"))
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	for key, value := range defaultConfig {
-		line := fmt.Sprintf("%s=%s\n", key, value)
-		if _, err := writer.WriteString(line); err != nil {
-			return fmt.Errorf("error writing to file: %v", err)
+	for _, legacyPath := range legacyPaths {
+		if legacyPath == path {
+			continue
+		}
+		if data, err := os.ReadFile(legacyPath); err == nil {
+			if legacy, ok := parseLegacyConfig(string(data)); ok {
+				Progress("Converting %s to %s, the old file is no longer read\n", legacyPath, path)
+				return migrateLegacyConfig(path, legacy)
+			}
 		}
 	}
-	if err := writer.Flush(); err != nil {
-		return fmt.Errorf("error flushing writer: %v", err)
+
+	Progress("Config file not found. Creating default config...\n")
+	if err := os.WriteFile(path, []byte(defaultConfig), 0644); err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
 	}
-	return nil
+	return []byte(defaultConfig), nil
 }
 
 func ChangeToken(config *MydConfig, user *User) {
 	fmt.Print("Enter your GitHub username: ")
 	fmt.Scanln(&user.Username)
-	
+
 	fmt.Print("Please generate a token and paste it here: ")
 	fmt.Scanln(&user.Token)
-	
+
 	err := WriteTokenToFile(user.Token, filepath.Join(os.ExpandEnv(config.StoragePath), "token"))
 	if err != nil {
 		Exit("Failed to save token", err)
 	}
-	
+
 	err = WriteTokenToFile(user.Username, filepath.Join(os.ExpandEnv(config.StoragePath), "username"))
 	if err != nil {
 		Exit("Failed to save username", err)
@@ -128,99 +286,429 @@ func ChangeToken(config *MydConfig, user *User) {
 
 // CreateOrWriteTokenFile creates the token file if it doesn't exist and writes the token to it
 func WriteTokenToFile(token string, filePath string) error {
-    // Extract the directory path
-    dir := filepath.Dir(filePath)
+	// Extract the directory path
+	dir := filepath.Dir(filePath)
 
-    // Create all necessary parent directories
-    if err := os.MkdirAll(dir, 0755); err != nil {
-        return fmt.Errorf("failed to create directories: %v", err)
-    }
+	// Create all necessary parent directories
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
 
-    // Write the token to the file, creating it if it doesn't exist
-    err := os.WriteFile(filePath, []byte(token), 0644)
-    if err != nil {
-        return fmt.Errorf("failed to write token to file: %v", err)
-    }
+	// Write the token to the file, creating it if it doesn't exist
+	err := os.WriteFile(filePath, []byte(token), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write token to file: %v", err)
+	}
 
-    return nil
+	return nil
 }
 
-// Load config file from disk into a map (key=value format)
-func loadConfigFromFile(path string) (map[string]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+// legacyKeys maps the keys of the old key=value format to their new names
+var legacyKeys = map[string]string{
+	"StoragePath":       "storage_path",
+	"UpstreamName":      "upstream.name",
+	"Username":          "upstream.username",
+	"CommitTemplate":    "commit.template",
+	"CommitIdentity":    "commit.identity",
+	"CommitName":        "commit.name",
+	"CommitEmail":       "commit.email",
+	"SignCommits":       "signing.enabled",
+	"SigningFormat":     "signing.format",
+	"SigningKey":        "signing.key",
+	"MaxFileSizeMB":     "files.max_size_mb",
+	"DetectBinaryFiles": "files.detect_binary",
+	"UseGitLFS":         "files.use_lfs",
+}
 
+// parseLegacyConfig reads the old key=value format. It reports false for
+// anything else.
+func parseLegacyConfig(data string) (map[string]string, bool) {
 	configMap := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue // Skip empty lines and comments
 		}
 
 		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			configMap[key] = value
+		if len(parts) != 2 {
+			return nil, false
+		}
+		key, ok := legacyKeys[strings.TrimSpace(parts[0])]
+		if !ok {
+			return nil, false
 		}
+		configMap[key] = strings.TrimSpace(parts[1])
 	}
+	return configMap, len(configMap) > 0
+}
 
-	if err := scanner.Err(); err != nil {
+// migrateLegacyConfig writes the legacy values into the default config, so
+// the converted file keeps its comments
+func migrateLegacyConfig(path string, legacy map[string]string) ([]byte, error) {
+	doc, _ := parseTOML(defaultConfig)
+	for key, value := range legacy {
+		if key == "commit.template" {
+			// Legacy values were single lines with newlines written as \n
+			value = strings.ReplaceAll(value, `\n`, "\n")
+		}
+		encoded, err := parseConfigValue(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		section, name := splitKey(key)
+		doc.set(section, name, encoded)
+	}
+
+	data := []byte(doc.String())
+	return data, os.WriteFile(path, data, 0644)
+}
+
+// configField is a settable MydConfig field and its dotted key
+type configField struct {
+	key   string
+	value reflect.Value
+}
+
+// configFields walks the config in struct order, descending into nested
+// structs, which become a level of the key
+func configFields(config *MydConfig) []configField {
+	var fields []configField
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			key := prefix + v.Type().Field(i).Tag.Get("config")
+			if v.Field(i).Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			fields = append(fields, configField{key, v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(config).Elem(), "")
+	return fields
+}
+
+func findConfigField(config *MydConfig, key string) (configField, error) {
+	for _, field := range configFields(config) {
+		if field.key == key {
+			return field, nil
+		}
+	}
+	return configField{}, &ConfigError{Key: key, Err: fmt.Errorf("unknown key, run 'myd config list' for the valid ones")}
+}
+
+// ConfigKeys returns every config key in struct order
+func ConfigKeys() []string {
+	var keys []string
+	for _, field := range configFields(&MydConfig{}) {
+		keys = append(keys, field.key)
+	}
+	return keys
+}
+
+// ConfigValue returns the value of key as a string, int, bool, duration or
+// []string
+func ConfigValue(config *MydConfig, key string) (interface{}, error) {
+	field, err := findConfigField(config, key)
+	if err != nil {
 		return nil, err
 	}
+	return field.value.Interface(), nil
+}
 
-	return configMap, nil
+// FormatConfigValue writes a value the way it appears in the config file
+func FormatConfigValue(value interface{}) string {
+	return encodeValue(value)
 }
 
-// Save updated config map to file in key=value format
-func saveConfigToFile(path string, configMap map[string]string) error {
-	file, err := os.Create(path)
+// parseConfigValue checks a value given on the command line against the
+// type of key and returns it as written in the config file. Lists are
+// either written as an array or separated by commas.
+func parseConfigValue(key, value string) (string, error) {
+	field, err := findConfigField(&MydConfig{}, key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := setFieldString(field.value, value); err != nil {
+		return "", err
+	}
+	return encodeValue(field.value.Interface()), nil
+}
+
+// setFieldString sets a field from a command line or environment value
+func setFieldString(fieldValue reflect.Value, value string) error {
+	var decoded interface{} = value
+	switch fieldValue.Interface().(type) {
+	case time.Duration:
+	case int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		decoded = n
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		decoded = b
+	case []string:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var err error
+			if decoded, err = decodeValue(strings.TrimSpace(value)); err != nil {
+				return err
+			}
+			break
+		}
+		items := []interface{}{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		decoded = items
+	}
+	return setField(fieldValue, decoded)
+}
+
+// SetConfigValue changes one key of the config file in place, refusing
+// values that would not load
+func SetConfigValue(configPath, key, value string) error {
+	configPath = os.ExpandEnv(configPath)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	doc, err := parseConfig(configPath, data)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	for key, value := range configMap {
-		line := fmt.Sprintf("%s=%s\n", key, value)
-		if _, err := writer.WriteString(line); err != nil {
-			return err
+	encoded, err := parseConfigValue(key, value)
+	if err != nil {
+		return &ConfigError{Path: configPath, Key: key, Err: err}
+	}
+	section, name := splitKey(key)
+	doc.set(section, name, encoded)
+	if _, err := populateConfig(configPath, doc); err != nil {
+		return err
+	}
+
+	if profile := selectedProfile(doc); profile != "" && doc.get("profiles."+profile, key) != nil {
+		Warn("profile %q overrides %s\n", profile, key)
+	}
+	return os.WriteFile(configPath, []byte(doc.String()), 0644)
+}
+
+// ValidateConfig returns every problem in the config file, including keys
+// myd does not know, which LoadConfig ignores
+func ValidateConfig(configPath string) []*ConfigError {
+	configPath = os.ExpandEnv(configPath)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return []*ConfigError{{Path: configPath, Err: err}}
+	}
+	doc, err := parseConfig(configPath, data)
+	if err != nil {
+		return []*ConfigError{err.(*ConfigError)}
+	}
+
+	var problems []*ConfigError
+	known := make(map[string]bool)
+	for _, key := range ConfigKeys() {
+		known[key] = true
+	}
+	for _, line := range doc.lines {
+		if line.key == "" {
+			continue
+		}
+		// Profile sections use the full dotted key
+		key := line.key
+		inProfile := strings.HasPrefix(line.section, "profiles.")
+		if !inProfile && line.section != "" {
+			key = line.section + "." + line.key
+		}
+
+		var err error
+		switch {
+		case !known[key] || (inProfile && key == "profile"):
+			err = fmt.Errorf("unknown key")
+		case !inProfile && strings.Contains(line.key, "."):
+			section, name := splitKey(key)
+			err = fmt.Errorf("write it as %s under [%s]", name, section)
+		default:
+			err = checkConfigLine(key, line)
 		}
+		if err != nil {
+			problems = append(problems, &ConfigError{Path: configPath, Line: line.number, Key: key, Err: err})
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// Profile problems are only found once the values are combined
+	if _, err := populateConfig(configPath, doc); err != nil {
+		return []*ConfigError{err.(*ConfigError)}
+	}
+	return nil
+}
+
+// checkConfigLine decodes a single line into a scratch config
+func checkConfigLine(key string, line *tomlLine) error {
+	var scratch MydConfig
+	field, err := findConfigField(&scratch, key)
+	if err != nil {
+		return err
 	}
-	return writer.Flush()
+	value, _ := decodeValue(line.value)
+	if err := setField(field.value, value); err != nil {
+		return err
+	}
+	return checkChoice(key, field.value)
 }
 
-// Populate the CurdConfig struct from a map
-func populateConfig(configMap map[string]string) MydConfig {
+func checkChoice(key string, value reflect.Value) error {
+	if choices, ok := configChoices[key]; ok && value.String() != "" {
+		for _, choice := range choices {
+			if value.String() == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+	}
+	if key == "paths.roots" {
+		for _, spec := range value.Interface().([]string) {
+			if _, err := parsePathRoot(spec); err != nil {
+				return err
+			}
+		}
+	}
+	if (value.Kind() == reflect.Int || value.Kind() == reflect.Int64) && value.Int() < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+func selectedProfile(doc *tomlDoc) string {
+	if profile, ok := os.LookupEnv(envName("profile")); ok {
+		return profile
+	}
+	if line := doc.get("", "profile"); line != nil {
+		value, _ := decodeValue(line.value)
+		profile, _ := value.(string)
+		return profile
+	}
+	return ""
+}
+
+// Populate the MydConfig struct from the document, applying the keys of the
+// selected profile on top
+func populateConfig(path string, doc *tomlDoc) (MydConfig, error) {
 	config := MydConfig{}
-	configValue := reflect.ValueOf(&config).Elem()
-
-	for i := 0; i < configValue.NumField(); i++ {
-		field := configValue.Type().Field(i)
-		tag := field.Tag.Get("config")
-
-		if value, exists := configMap[tag]; exists {
-			fieldValue := configValue.FieldByName(field.Name)
-
-			if fieldValue.CanSet() {
-				switch fieldValue.Kind() {
-				case reflect.String:
-					fieldValue.SetString(value)
-				case reflect.Int:
-					intVal, _ := strconv.Atoi(value)
-					fieldValue.SetInt(int64(intVal))
-				case reflect.Bool:
-					boolVal, _ := strconv.ParseBool(value)
-					fieldValue.SetBool(boolVal)
-				}
+
+	profile := selectedProfile(doc)
+	profileSection := "profiles." + profile
+	if profile != "" && !doc.hasSection(profileSection) {
+		configErr := &ConfigError{Path: path, Key: "profile", Err: fmt.Errorf("there is no [%s] section", profileSection)}
+		if _, ok := os.LookupEnv(envName("profile")); ok {
+			configErr.Path, configErr.Key = "", envName("profile")
+		} else if line := doc.get("", "profile"); line != nil {
+			configErr.Line = line.number
+		}
+		return MydConfig{}, configErr
+	}
+
+	for _, field := range configFields(&config) {
+		section, name := splitKey(field.key)
+		line := doc.get(section, name)
+		if profile != "" {
+			if override := doc.get(profileSection, field.key); override != nil {
+				line = override
 			}
 		}
+		if line == nil {
+			continue
+		}
+
+		value, _ := decodeValue(line.value)
+		err := setField(field.value, value)
+		if err == nil {
+			err = checkChoice(field.key, field.value)
+		}
+		if err != nil {
+			return MydConfig{}, &ConfigError{Path: path, Line: line.number, Key: field.key, Err: err}
+		}
+	}
+
+	// MYD_* variables win over the file, so sandboxes can run without it
+	for _, field := range configFields(&config) {
+		value, ok := os.LookupEnv(envName(field.key))
+		if !ok {
+			continue
+		}
+		err := setFieldString(field.value, value)
+		if err == nil {
+			err = checkChoice(field.key, field.value)
+		}
+		if err != nil {
+			return MydConfig{}, &ConfigError{Key: envName(field.key), Err: err}
+		}
+	}
+
+	if config.StoragePath == "" {
+		config.StoragePath = DefaultStoragePath()
+	}
+	return config, nil
+}
+
+func setField(fieldValue reflect.Value, value interface{}) error {
+	if fieldValue.Type() == reflect.TypeOf(time.Duration(0)) {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a duration such as \"30s\" or \"5m\"")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as \"30s\" or \"5m\"")
+		}
+		fieldValue.SetInt(int64(d))
+		return nil
 	}
 
-	return config
+	switch fieldValue.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		fieldValue.SetString(s)
+	case reflect.Int:
+		n, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("must be a number")
+		}
+		fieldValue.SetInt(n)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("must be true or false")
+		}
+		fieldValue.SetBool(b)
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be a list of strings")
+		}
+		strs := make([]string, len(items))
+		for i, item := range items {
+			if strs[i], ok = item.(string); !ok {
+				return fmt.Errorf("must be a list of strings")
+			}
+		}
+		fieldValue.Set(reflect.ValueOf(strs))
+	}
+	return nil
 }
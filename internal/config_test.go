@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testConfigPath points MYD_CONFIG at a file in a fresh directory, so
+// nothing reads or converts the config of the user running the tests
+func testConfigPath(t *testing.T, data string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	path := filepath.Join(dir, "config.toml")
+	t.Setenv("MYD_CONFIG", path)
+	if data != "" {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoadConfigMigratesLegacyFormat(t *testing.T) {
+	legacy := `StoragePath=$HOME/.local/share/myd
+UpstreamName=old-dotfiles
+CommitTemplate=myd: {{.Subject}}\n\n{{.Body}}
+SignCommits=true
+MaxFileSizeMB=10
+`
+	path := testConfigPath(t, legacy)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.UpstreamName != "old-dotfiles" {
+		t.Errorf("UpstreamName = %q", config.UpstreamName)
+	}
+	if config.CommitTemplate != "myd: {{.Subject}}\n\n{{.Body}}" {
+		t.Errorf("CommitTemplate = %q, the \\n were not converted", config.CommitTemplate)
+	}
+	if !config.Signing.Enabled || config.MaxFileSizeMB != 10 {
+		t.Errorf("Signing.Enabled = %v, MaxFileSizeMB = %d", config.Signing.Enabled, config.MaxFileSizeMB)
+	}
+
+	old, err := os.ReadFile(path + ".old")
+	if err != nil || string(old) != legacy {
+		t.Errorf("legacy file was not kept as .old: %v", err)
+	}
+	if errs := ValidateConfig(path); len(errs) > 0 {
+		t.Errorf("converted config does not validate: %v", errs)
+	}
+}
+
+func TestParseLegacyConfigRejectsTOML(t *testing.T) {
+	if _, ok := parseLegacyConfig(defaultConfig); ok {
+		t.Error("the default config.toml was taken for a legacy config")
+	}
+	if _, ok := parseLegacyConfig("UpstreamName=dotfiles\nUnknownKey=1\n"); ok {
+		t.Error("a file with unknown keys was taken for a legacy config")
+	}
+}
+
+func TestLoadConfigAddsMissingKeys(t *testing.T) {
+	path := testConfigPath(t, `# my own comment
+[upstream]
+name = "mine" # keep this
+`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.UpstreamName != "mine" || config.MaxFileSizeMB != 50 {
+		t.Errorf("UpstreamName = %q, MaxFileSizeMB = %d", config.UpstreamName, config.MaxFileSizeMB)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# my own comment\n", `name = "mine" # keep this`, "max_size_mb = 50", "[hooks]"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config is missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestLoadConfigProfileAndEnvironment(t *testing.T) {
+	path := testConfigPath(t, `profile = "work"
+
+[upstream]
+name = "dotfiles"
+
+[ignore]
+patterns = ["*.log"]
+
+[profiles.work]
+upstream.name = "work-dotfiles"
+commit.email = "me@work.example"
+`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.UpstreamName != "work-dotfiles" || config.CommitEmail != "me@work.example" {
+		t.Errorf("profile not applied: %q %q", config.UpstreamName, config.CommitEmail)
+	}
+
+	t.Setenv("MYD_PROFILE", "")
+	t.Setenv("MYD_IGNORE_PATTERNS", "*.tmp, .cache")
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.UpstreamName != "dotfiles" {
+		t.Errorf("MYD_PROFILE did not turn the profile off: %q", config.UpstreamName)
+	}
+	if want := []string{"*.tmp", ".cache"}; !reflect.DeepEqual(config.IgnorePatterns, want) {
+		t.Errorf("IgnorePatterns = %q, want %q", config.IgnorePatterns, want)
+	}
+
+	t.Setenv("MYD_FILES_MAX_SIZE_MB", "lots")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "MYD_FILES_MAX_SIZE_MB") {
+		t.Errorf("invalid environment value error = %v", err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	path := testConfigPath(t, `[upstream]
+name = "dotfiles"
+
+[commit]
+identity = "somebody"
+
+[files]
+colour = true
+max_size_mb = -1
+
+[profiles.work]
+upstream.name = "work"
+`)
+	want := map[string]int{"commit.identity": 5, "files.colour": 8, "files.max_size_mb": 9}
+	errs := ValidateConfig(path)
+	got := make(map[string]int)
+	for _, err := range errs {
+		got[err.Key] = err.Line
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateConfig() reported %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Sync states of a tracked path compared with the last upload
+const (
+	StateSynced   = "synced"
+	StateModified = "modified"
+	StateNew      = "not uploaded"
+	StateMissing  = "missing"
+)
+
+// TrackedState is a tracked path and how it compares with the last upload
+type TrackedState struct {
+	Path  string
+	State string
+}
+
+// DashboardState is everything the dashboard shows
+type DashboardState struct {
+	Remote     string
+	LastUpload *Snapshot
+	Paths      []TrackedState
+	Err        error
+}
+
+type dashboardLoadedMsg DashboardState
+
+type commandDoneMsg struct {
+	name   string
+	output string
+	err    error
+}
+
+// DashboardModel is the overview shown when myd runs without arguments. The
+// actions run the myd executable itself so each command keeps its own UI.
+type DashboardModel struct {
+	load     func() DashboardState
+	myd      string
+	mydFlags []string // global flags passed on to every myd command
+	state    DashboardState
+
+	cursor   int
+	loading  bool
+	running  string
+	status   string
+	quitting bool
+}
+
+// NewDashboardModel builds the dashboard. load gathers the state and is
+// called again after every action, myd is the path of the myd executable and
+// mydFlags the global flags it was started with.
+func NewDashboardModel(load func() DashboardState, myd string, mydFlags ...string) *DashboardModel {
+	return &DashboardModel{load: load, myd: myd, mydFlags: mydFlags, loading: true}
+}
+
+func (m *DashboardModel) command(args ...string) *exec.Cmd {
+	return exec.Command(m.myd, append(append([]string{}, m.mydFlags...), args...)...)
+}
+
+func (m *DashboardModel) refresh() tea.Cmd {
+	m.loading = true
+	return func() tea.Msg {
+		return dashboardLoadedMsg(m.load())
+	}
+}
+
+func (m *DashboardModel) selected() string {
+	if m.cursor < len(m.state.Paths) {
+		return m.state.Paths[m.cursor].Path
+	}
+	return ""
+}
+
+// modified counts the tracked paths with changes to upload
+func (m *DashboardModel) modified() int {
+	count := 0
+	for _, path := range m.state.Paths {
+		if path.State != StateSynced {
+			count++
+		}
+	}
+	return count
+}
+
+// interactive hands the terminal to a myd command with its own UI
+func (m *DashboardModel) interactive(args ...string) tea.Cmd {
+	name := args[0]
+	cmd := m.command(args...)
+	// Keep the pager open for short diffs instead of returning straight away
+	cmd.Env = append(os.Environ(), "LESS=R")
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return commandDoneMsg{name: name, err: err}
+	})
+}
+
+// background runs a myd command while the dashboard stays on screen
+func (m *DashboardModel) background(args ...string) tea.Cmd {
+	m.running = args[0]
+	return func() tea.Msg {
+		output, err := m.command(args...).CombinedOutput()
+		return commandDoneMsg{name: args[0], output: string(output), err: err}
+	}
+}
+
+func (m *DashboardModel) Init() tea.Cmd {
+	return m.refresh()
+}
+
+func (m *DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case dashboardLoadedMsg:
+		m.state = DashboardState(msg)
+		m.loading = false
+		if m.cursor >= len(m.state.Paths) {
+			m.cursor = max(len(m.state.Paths)-1, 0)
+		}
+	case commandDoneMsg:
+		m.running = ""
+		lines := strings.Split(strings.TrimSpace(msg.output), "\n")
+		last := lines[len(lines)-1]
+		switch {
+		case msg.err != nil && last != "":
+			m.status = fmt.Sprintf("%s failed: %s", msg.name, last)
+		case msg.err != nil:
+			m.status = fmt.Sprintf("%s failed: %v", msg.name, msg.err)
+		default:
+			m.status = last
+		}
+		return m, m.refresh()
+	case tea.KeyMsg:
+		if m.running != "" {
+			if msg.String() == "ctrl+c" {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		m.status = ""
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.state.Paths)-1 {
+				m.cursor++
+			}
+		case "a":
+			return m, m.interactive("add", "-i")
+		case "i":
+			if path := m.selected(); path != "" && !IsGlob(path) {
+				return m, m.interactive("ignore", "--in", path)
+			}
+			return m, m.interactive("ignore")
+		case "d":
+			return m, m.interactive("delete")
+		case "D":
+			if m.modified() == 0 {
+				m.status = "Everything up-to-date"
+				return m, nil
+			}
+			if path := m.selected(); path != "" && !IsGlob(path) && m.state.Paths[m.cursor].State == StateModified {
+				return m, m.interactive("diff", path)
+			}
+			return m, m.interactive("diff")
+		case "u":
+			return m, m.background("upload")
+		case "p":
+			return m, m.background("pull")
+		case "r":
+			return m, m.refresh()
+		}
+	}
+	return m, nil
+}
+
+func (m *DashboardModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	faintStyle := lipgloss.NewStyle().Faint(true)
+	stateStyles := map[string]lipgloss.Style{
+		StateSynced:   lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		StateModified: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		StateNew:      lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+		StateMissing:  lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+	}
+
+	s := style.Render("myd") + "\n\n"
+	s += fmt.Sprintf("Repository:  %s\n", m.state.Remote)
+	if last := m.state.LastUpload; last != nil {
+		s += fmt.Sprintf("Last upload: %s %s\n", last.Date.Local().Format("2006-01-02 15:04"),
+			faintStyle.Render(fmt.Sprintf("(%s ago, %s)", time.Since(last.Date).Round(time.Minute), last.Subject)))
+	} else if !m.loading {
+		s += "Last upload: never\n"
+	}
+	s += "\n"
+
+	switch {
+	case m.loading && len(m.state.Paths) == 0:
+		s += "  Checking tracked paths...\n"
+	case m.state.Err != nil:
+		s += "  " + style.Render(m.state.Err.Error()) + "\n"
+	case len(m.state.Paths) == 0:
+		s += "  No paths are being tracked, press a to add some\n"
+	}
+
+	width := 0
+	for _, path := range m.state.Paths {
+		width = max(width, len(path.Path))
+	}
+	for i, path := range m.state.Paths {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		line := fmt.Sprintf("%s %-*s", cursor, width, path.Path)
+		if m.cursor == i {
+			line = selectedStyle.Render(line)
+		}
+		s += line + "  " + stateStyles[path.State].Render(path.State) + "\n"
+	}
+
+	s += "\n"
+	switch {
+	case m.running != "":
+		s += style.Render(fmt.Sprintf("Running %s...", m.running)) + "\n"
+	case m.loading && len(m.state.Paths) > 0:
+		s += faintStyle.Render("Refreshing...") + "\n"
+	case m.status != "":
+		s += style.Render(m.status) + "\n"
+	}
+
+	s += "\n" + faintStyle.Render("a add  i ignore  d delete  D diff  u upload  p pull  r refresh  q quit") + "\n"
+	return s
+}
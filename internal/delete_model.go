@@ -2,8 +2,6 @@ package internal
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,37 +9,70 @@ import (
 )
 
 type DeleteModel struct {
-	config    *MydConfig
-	paths     []string
-	cursor    int
-	selected  map[int]bool
-	quitting  bool
+	config   *MydConfig
+	paths    []string
+	original []string
+	filter   string
+	visible  []string // paths matching the filter, best match first
+	selected map[string]bool
+	history  [][]string // previous path lists, for undo
+
+	cursor     int
+	offset     int
+	height     int
+	confirming bool
+	status     string
+	quitting   bool
 }
 
 func NewDeleteModel(config *MydConfig) *DeleteModel {
-	paths := loadPaths(config)
-	return &DeleteModel{
-		config:    config,
-		paths:     paths,
-		selected:  make(map[int]bool),
-		quitting:  false,
+	paths := LoadTrackedPaths(config)
+	m := &DeleteModel{
+		config:   config,
+		paths:    paths,
+		original: paths,
+		selected: make(map[string]bool),
+		height:   24,
 	}
+	m.applyFilter()
+	return m
 }
 
-func loadPaths(config *MydConfig) []string {
-	uploadListPath := filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
-	data, err := os.ReadFile(uploadListPath)
-	if err != nil {
-		return []string{}
+// Removed returns the paths that were untracked when the menu closed,
+// leaving out deletions that were undone
+func (m *DeleteModel) Removed() []string {
+	kept := make(map[string]bool)
+	for _, path := range m.paths {
+		kept[path] = true
+	}
+
+	var removed []string
+	for _, path := range m.original {
+		if !kept[path] {
+			removed = append(removed, path)
+		}
 	}
+	return removed
+}
+
+func (m *DeleteModel) applyFilter() {
+	m.visible = FuzzyFilter(m.filter, m.paths)
+	m.cursor, m.offset = 0, 0
+}
 
-	var paths []string
-	for _, line := range strings.Split(string(data), "\n") {
-		if line = strings.TrimSpace(line); line != "" {
-			paths = append(paths, line)
+// chosen returns the selected paths in list order
+func (m *DeleteModel) chosen() []string {
+	var chosen []string
+	for _, path := range m.paths {
+		if m.selected[path] {
+			chosen = append(chosen, path)
 		}
 	}
-	return paths
+	return chosen
+}
+
+func (m *DeleteModel) listHeight() int {
+	return max(m.height-8, 3)
 }
 
 func (m *DeleteModel) Init() tea.Cmd {
@@ -50,64 +81,160 @@ func (m *DeleteModel) Init() tea.Cmd {
 
 func (m *DeleteModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		if m.confirming {
+			switch msg.String() {
+			case "y", "enter":
+				m.deleteSelected()
+			case "n", "esc":
+				m.confirming = false
+			case "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		m.status = ""
+		switch msg.Type {
+		case tea.KeyCtrlC:
 			m.quitting = true
 			return m, tea.Quit
-		case "up", "k":
+		case tea.KeyEsc:
+			// The first escape clears the filter
+			if m.filter == "" {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.filter = ""
+			m.applyFilter()
+		case tea.KeyUp:
 			if m.cursor > 0 {
 				m.cursor--
 			}
-		case "down", "j":
-			if m.cursor < len(m.paths)-1 {
+		case tea.KeyDown:
+			if m.cursor < len(m.visible)-1 {
 				m.cursor++
 			}
-		case " ":
-			m.selected[m.cursor] = !m.selected[m.cursor]
-			return m, nil
-		case "enter":
-			if len(m.selected) > 0 {
-				return m, tea.Sequence(
-					m.deleteSelected,
-					func() tea.Msg { return nil },
-				)
+		case tea.KeyPgUp:
+			m.cursor = max(m.cursor-m.listHeight(), 0)
+		case tea.KeyPgDown:
+			m.cursor = max(min(m.cursor+m.listHeight(), len(m.visible)-1), 0)
+		case tea.KeySpace:
+			if len(m.visible) > 0 {
+				path := m.visible[m.cursor]
+				m.selected[path] = !m.selected[path]
+			}
+		case tea.KeyCtrlA:
+			for _, path := range m.visible {
+				m.selected[path] = true
+			}
+		case tea.KeyCtrlR:
+			for _, path := range m.visible {
+				m.selected[path] = !m.selected[path]
+			}
+		case tea.KeyCtrlZ:
+			m.undo()
+		case tea.KeyEnter:
+			if len(m.chosen()) > 0 {
+				m.confirming = true
+			} else {
+				m.status = "Nothing selected"
 			}
+		case tea.KeyBackspace:
+			if m.filter != "" {
+				runes := []rune(m.filter)
+				m.filter = string(runes[:len(runes)-1])
+				m.applyFilter()
+			}
+		case tea.KeyRunes:
+			m.filter += string(msg.Runes)
+			m.applyFilter()
+		}
+
+		// Keep the cursor inside the viewport
+		if m.cursor < m.offset {
+			m.offset = m.cursor
+		} else if m.cursor >= m.offset+m.listHeight() {
+			m.offset = m.cursor - m.listHeight() + 1
 		}
-	case error:
-		m.quitting = true
-		return m, tea.Quit
 	}
 	return m, nil
 }
 
-func (m *DeleteModel) deleteSelected() tea.Msg {
+func (m *DeleteModel) deleteSelected() {
+	chosen := m.chosen()
+
 	// Create a new slice without the selected paths
 	var newPaths []string
-	for i, path := range m.paths {
-		if !m.selected[i] {
+	for _, path := range m.paths {
+		if !m.selected[path] {
 			newPaths = append(newPaths, path)
 		}
 	}
 
 	// Write the new paths back to toupload.txt
-	uploadListPath := filepath.Join(os.ExpandEnv(m.config.StoragePath), "toupload.txt")
-	content := strings.Join(newPaths, "\n")
-	if len(newPaths) > 0 {
-		content += "\n"
-	}
-	
-	if err := os.WriteFile(uploadListPath, []byte(content), 0644); err != nil {
-		return err
+	if err := SaveTrackedPaths(m.config, newPaths); err != nil {
+		m.status = fmt.Sprintf("Failed to save: %v", err)
+		m.confirming = false
+		return
 	}
 
-	// Update the model's paths
+	m.history = append(m.history, m.paths)
 	m.paths = newPaths
-	m.selected = make(map[int]bool)
-	if m.cursor >= len(m.paths) {
-		m.cursor = len(m.paths) - 1
+	m.selected = make(map[string]bool)
+	m.confirming = false
+	m.applyFilter()
+	m.status = fmt.Sprintf("Untracked %d %s, press ctrl+z to undo", len(chosen), pluralPaths(len(chosen)))
+}
+
+func (m *DeleteModel) undo() {
+	if len(m.history) == 0 {
+		m.status = "Nothing to undo"
+		return
 	}
-	return nil
+
+	previous := m.history[len(m.history)-1]
+	if err := SaveTrackedPaths(m.config, previous); err != nil {
+		m.status = fmt.Sprintf("Failed to undo: %v", err)
+		return
+	}
+	restored := len(previous) - len(m.paths)
+	m.history = m.history[:len(m.history)-1]
+	m.paths = previous
+	m.applyFilter()
+	m.status = fmt.Sprintf("Restored %d %s", restored, pluralPaths(restored))
+}
+
+func pluralPaths(n int) string {
+	if n == 1 {
+		return "path"
+	}
+	return "paths"
+}
+
+// highlight renders path in base, with the characters matched by the filter in match
+func (m *DeleteModel) highlight(path string, base, match lipgloss.Style) string {
+	_, positions, _ := FuzzyMatch(m.filter, path)
+	if len(positions) == 0 {
+		return base.Render(path)
+	}
+
+	matched := make(map[int]bool)
+	for _, i := range positions {
+		matched[i] = true
+	}
+	var b strings.Builder
+	for i, r := range path {
+		if matched[i] {
+			b.WriteString(match.Render(string(r)))
+		} else {
+			b.WriteString(base.Render(string(r)))
+		}
+	}
+	return b.String()
 }
 
 func (m *DeleteModel) View() string {
@@ -115,34 +242,66 @@ func (m *DeleteModel) View() string {
 		return ""
 	}
 
-	if len(m.paths) == 0 {
-		return "No paths are being tracked\n\nPress q to quit"
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	faintStyle := lipgloss.NewStyle().Faint(true)
+	plainStyle := lipgloss.NewStyle()
+	matchStyle := plainStyle.Copy().Underline(true)
+
+	if m.confirming {
+		chosen := m.chosen()
+		s := fmt.Sprintf("Stop tracking these %d %s?\n\n", len(chosen), pluralPaths(len(chosen)))
+		for i, path := range chosen {
+			if i == m.listHeight() {
+				s += faintStyle.Render(fmt.Sprintf("  ... and %d more", len(chosen)-i)) + "\n"
+				break
+			}
+			s += "  " + path + "\n"
+		}
+		s += "\nPress y to untrack, n to go back\n"
+		return s
 	}
 
-	s := "Select paths to delete (space to select, enter to delete):\n\n"
+	if len(m.paths) == 0 {
+		s := "No paths are being tracked\n"
+		if m.status != "" {
+			s += "\n" + style.Render(m.status) + "\n"
+		}
+		return s + "\nPress esc to quit\n"
+	}
 
-	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	selectedStyle := style.Copy().Bold(true)
+	s := "Select paths to delete (type to filter, space to select, enter to delete):\n\n"
+	s += "> " + m.filter + faintStyle.Render(fmt.Sprintf("  %d/%d", len(m.visible), len(m.paths))) + "\n\n"
+	if len(m.visible) == 0 {
+		s += "  (no matches)\n"
+	}
 
-	for i, path := range m.paths {
+	end := min(m.offset+m.listHeight(), len(m.visible))
+	for i := m.offset; i < end; i++ {
+		path := m.visible[i]
 		cursor := " "
 		if m.cursor == i {
 			cursor = ">"
 		}
 
 		checked := " "
-		if m.selected[i] {
+		if m.selected[path] {
 			checked = "x"
 		}
 
-		line := fmt.Sprintf("%s [%s] %s", cursor, checked, path)
 		if m.cursor == i {
-			s += selectedStyle.Render(line) + "\n"
+			s += selectedStyle.Render(fmt.Sprintf("%s [%s] ", cursor, checked)) + m.highlight(path, selectedStyle, selectedStyle.Copy().Underline(true)) + "\n"
 		} else {
-			s += line + "\n"
+			s += fmt.Sprintf("%s [%s] ", cursor, checked) + m.highlight(path, plainStyle, matchStyle) + "\n"
 		}
 	}
 
-	s += "\nPress q to quit\n"
+	if count := len(m.chosen()); count > 0 {
+		s += fmt.Sprintf("\n%d selected\n", count)
+	}
+	if m.status != "" {
+		s += "\n" + style.Render(m.status) + "\n"
+	}
+	s += "\n" + faintStyle.Render("ctrl+a select all  ctrl+r invert  ctrl+z undo  esc quit") + "\n"
 	return s
-} 
\ No newline at end of file
+}
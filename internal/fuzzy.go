@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch reports whether the characters of pattern appear in s in order,
+// ignoring case. The score favours consecutive characters and matches at the
+// start of path components. positions holds the matched byte offsets in s.
+func FuzzyMatch(pattern, s string) (score int, positions []int, ok bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+
+	needle := []rune(strings.ToLower(pattern))
+	n := 0
+	previous, last := -2, -1
+	prevRune := '/'
+	for i, r := range s {
+		if n < len(needle) && unicode.ToLower(r) == needle[n] {
+			score++
+			if previous == last {
+				score += 2
+			}
+			if prevRune == '/' || prevRune == '.' || prevRune == '-' || prevRune == '_' {
+				score += 3
+			}
+			positions = append(positions, i)
+			previous = i
+			n++
+		}
+		prevRune, last = r, i
+	}
+	if n < len(needle) {
+		return 0, nil, false
+	}
+	// Prefer shorter strings when everything else is equal
+	return score*100 - len(s), positions, true
+}
+
+// FuzzyFilter returns the items matching pattern, best matches first
+func FuzzyFilter(pattern string, items []string) []string {
+	type match struct {
+		item  string
+		score int
+	}
+
+	var matches []match
+	for _, item := range items {
+		if score, _, ok := FuzzyMatch(pattern, item); ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+	}
+
+	filtered := make([]string, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.item
+	}
+	return filtered
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Verbose prints every git command run through Git and GitOutput to stderr
+var Verbose bool
+
+func logGit(dir string, args []string) {
+	if Verbose {
+		fmt.Fprintf(os.Stderr, "+ git %s (in %s)\n", strings.Join(args, " "), dir)
+	}
+}
+
+// Git runs a git command inside dir and returns its trimmed combined output
+func Git(dir string, args ...string) (string, error) {
+	logGit(dir, args)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GitOutput runs a git command inside dir and returns its untouched standard output
+func GitOutput(dir string, args ...string) ([]byte, error) {
+	logGit(dir, args)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return output, nil
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IsGlob reports whether a tracked path is a pattern rather than a plain path
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// GlobBase returns the longest leading directory of a pattern without wildcards
+func GlobBase(pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	var base []string
+	for _, segment := range segments {
+		if IsGlob(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+
+	// The last segment is the pattern itself when nothing matched a wildcard
+	if len(base) == len(segments) {
+		base = base[:len(base)-1]
+	}
+	if joined := strings.Join(base, "/"); joined != "" {
+		return filepath.FromSlash(joined)
+	}
+	return "/"
+}
+
+// globRest returns the part of a pattern below its base, slash separated
+func globRest(pattern string) string {
+	rest := strings.TrimPrefix(filepath.ToSlash(pattern), filepath.ToSlash(GlobBase(pattern)))
+	return strings.TrimPrefix(rest, "/")
+}
+
+// GlobEntryName returns the repository directory holding the matches of a
+// pattern. The hash keeps patterns with the same base apart, and apart
+// from a tracked directory of that name.
+func GlobEntryName(pattern string) string {
+	sum := sha256.Sum256([]byte(pattern))
+	return filepath.Base(GlobBase(pattern)) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// MatchGlobBelow reports whether a path relative to the pattern's base is a
+// match or lies inside one
+func MatchGlobBelow(pattern, rel string) bool {
+	rest := globRest(pattern)
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i <= len(parts); i++ {
+		if MatchGlob(rest, strings.Join(parts[:i], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash separated path against a pattern where "**"
+// stands for any number of directories and other segments follow filepath.Match
+func MatchGlob(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated ** and try every possible split point
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// ExpandGlob returns the existing paths matching a pattern, sorted
+func ExpandGlob(pattern string) ([]string, error) {
+	base := GlobBase(pattern)
+	rest := globRest(pattern)
+	depth := len(strings.Split(rest, "/"))
+	recursive := strings.Contains(rest, "**")
+
+	var matches []string
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the whole pattern
+			if d != nil && d.IsDir() && path != base {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if path == base {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if MatchGlob(rest, rel) {
+			matches = append(matches, path)
+		}
+
+		// Without ** nothing deeper than the pattern can match
+		if d.IsDir() && !recursive && len(strings.Split(rel, "/")) >= depth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
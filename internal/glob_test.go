@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobBase(t *testing.T) {
+	tests := map[string]string{
+		"/home/me/.config/*/config.toml": "/home/me/.config",
+		"/home/me/.local/bin/**":         "/home/me/.local/bin",
+		"/home/me/*.conf":                "/home/me",
+		"/*.conf":                        "/",
+		"$XDG_CONFIG_HOME/*/foo":         "$XDG_CONFIG_HOME",
+	}
+	for pattern, want := range tests {
+		if got := GlobBase(pattern); got != want {
+			t.Errorf("GlobBase(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.conf", "a.conf", true},
+		{"*.conf", "dir/a.conf", false},
+		{"*/config.toml", "alacritty/config.toml", true},
+		{"*/config.toml", "a/b/config.toml", false},
+		{"**", "a/b/c", true},
+		{"**/*.lua", "init.lua", true},
+		{"**/*.lua", "lua/plugins/init.lua", true},
+		{"**/*.lua", "lua/plugins/init.vim", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/c", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"[ab].txt", "b.txt", true},
+		{"[ab].txt", "c.txt", false},
+		{"[", "[", false},
+	}
+	for _, test := range tests {
+		if got := MatchGlob(test.pattern, test.path); got != test.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+func TestMatchGlobBelow(t *testing.T) {
+	pattern := "/home/me/.config/*/foo"
+	for rel, want := range map[string]bool{
+		"a/foo":        true,
+		"a/foo/nested": true,
+		"a/bar":        false,
+		"a":            false,
+		"other":        false,
+	} {
+		if got := MatchGlobBelow(pattern, rel); got != want {
+			t.Errorf("MatchGlobBelow(%q, %q) = %v, want %v", pattern, rel, got, want)
+		}
+	}
+}
+
+func TestGlobEntryName(t *testing.T) {
+	foo := GlobEntryName("/home/me/.config/*/foo")
+	bar := GlobEntryName("/home/me/.config/*/bar")
+	if foo == bar {
+		t.Errorf("patterns with the same base share the entry %s", foo)
+	}
+	if foo == ".config" {
+		t.Errorf("pattern entry %s collides with a tracked directory", foo)
+	}
+	if again := GlobEntryName("/home/me/.config/*/foo"); again != foo {
+		t.Errorf("GlobEntryName is not stable: %s and %s", foo, again)
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{
+		"a/config.toml",
+		"b/config.toml",
+		"b/nested/config.toml",
+		"c/other.toml",
+		"lua/init.lua",
+		"lua/plugins/cmp.lua",
+	} {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string][]string{
+		"*/config.toml":  {"a/config.toml", "b/config.toml"},
+		"**/config.toml": {"a/config.toml", "b/config.toml", "b/nested/config.toml"},
+		"lua/**/*.lua":   {"lua/init.lua", "lua/plugins/cmp.lua"},
+		"*/missing.toml": nil,
+		"[ab]/*.toml":    {"a/config.toml", "b/config.toml"},
+		"c/*":            {"c/other.toml"},
+	}
+	for pattern, want := range tests {
+		matches, err := ExpandGlob(filepath.Join(root, pattern))
+		if err != nil {
+			t.Errorf("ExpandGlob(%q): %v", pattern, err)
+			continue
+		}
+		var got []string
+		for _, match := range matches {
+			rel, _ := filepath.Rel(root, match)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExpandGlob(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
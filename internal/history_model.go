@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// HistoryModel browses the snapshots that touched a single tracked file
+type HistoryModel struct {
+	repoPath   string
+	repoRel    string // path of the file inside the repository
+	dest       string // live location of the file
+	snapshots  []Snapshot
+	diffs      map[string][]string
+	cursor     int
+	diffOffset int
+	height     int
+	status     string
+	quitting   bool
+}
+
+// NewHistoryModel lists the snapshots touching repoRel, which installs to dest
+func NewHistoryModel(repoPath, repoRel, dest string) (*HistoryModel, error) {
+	snapshots, err := ListSnapshots(repoPath, 0, repoRel)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryModel{
+		repoPath:  repoPath,
+		repoRel:   repoRel,
+		dest:      dest,
+		snapshots: snapshots,
+		diffs:     make(map[string][]string),
+		height:    24,
+	}, nil
+}
+
+func (m *HistoryModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *HistoryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+				m.diffOffset = 0
+			}
+		case "down", "j":
+			if m.cursor < len(m.snapshots)-1 {
+				m.cursor++
+				m.diffOffset = 0
+			}
+		case "pgdown", "J":
+			m.diffOffset += m.diffHeight() / 2
+			if limit := len(m.currentDiff()) - m.diffHeight(); m.diffOffset > limit {
+				m.diffOffset = max(limit, 0)
+			}
+		case "pgup", "K":
+			m.diffOffset = max(m.diffOffset-m.diffHeight()/2, 0)
+		case "r":
+			if len(m.snapshots) > 0 {
+				m.status = m.restore(m.snapshots[m.cursor])
+			}
+		}
+	}
+	return m, nil
+}
+
+// restore writes the file as it was in the snapshot back to its live location
+func (m *HistoryModel) restore(snapshot Snapshot) string {
+	files, err := Git(m.repoPath, "-c", "core.quotePath=false", "ls-tree", "-r", "--name-only", snapshot.Hash, "--", m.repoRel)
+	if err != nil {
+		return fmt.Sprintf("Restore failed: %v", err)
+	}
+	if files == "" {
+		return fmt.Sprintf("%s does not exist in %s", m.dest, snapshot.Short())
+	}
+
+	for _, file := range strings.Split(files, "\n") {
+		if filepath.Base(file) == ".original_path" {
+			continue
+		}
+		content, err := GitOutput(m.repoPath, "show", snapshot.Hash+":"+file)
+		if err != nil {
+			return fmt.Sprintf("Restore failed: %v", err)
+		}
+
+		rel, _ := filepath.Rel(m.repoRel, file)
+		dest := filepath.Join(m.dest, rel)
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(dest); err == nil {
+			mode = info.Mode()
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Sprintf("Restore failed: %v", err)
+		}
+		if err := os.WriteFile(dest, content, mode); err != nil {
+			return fmt.Sprintf("Restore failed: %v", err)
+		}
+	}
+	return fmt.Sprintf("Restored %s from %s", m.dest, snapshot.Short())
+}
+
+func (m *HistoryModel) currentDiff() []string {
+	if len(m.snapshots) == 0 {
+		return nil
+	}
+
+	hash := m.snapshots[m.cursor].Hash
+	if diff, ok := m.diffs[hash]; ok {
+		return diff
+	}
+
+	output, err := Git(m.repoPath, "show", "--format=", hash, "--", m.repoRel)
+	if err != nil {
+		output = err.Error()
+	}
+	diff := strings.Split(output, "\n")
+	m.diffs[hash] = diff
+	return diff
+}
+
+// listHeight is the number of snapshot rows shown above the diff
+func (m *HistoryModel) listHeight() int {
+	return min(len(m.snapshots), max(m.height/3, 3))
+}
+
+func (m *HistoryModel) diffHeight() int {
+	return max(m.height-m.listHeight()-7, 5)
+}
+
+func (m *HistoryModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	if len(m.snapshots) == 0 {
+		return fmt.Sprintf("No snapshots touch %s\n\nPress q to quit", m.dest)
+	}
+
+	s := fmt.Sprintf("History of %s (r to restore, J/K to scroll diff):\n\n", m.dest)
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	addStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	delStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	faintStyle := lipgloss.NewStyle().Faint(true)
+
+	// Keep the cursor inside the visible window of the list
+	start := 0
+	if height := m.listHeight(); m.cursor >= height {
+		start = m.cursor - height + 1
+	}
+	for i := start; i < start+m.listHeight(); i++ {
+		snapshot := m.snapshots[i]
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+
+		host := snapshot.Host
+		if host == "" {
+			host = "-"
+		}
+		line := fmt.Sprintf("%s %s  %s  %-12s %s", cursor, snapshot.Short(), snapshot.Date.Local().Format("2006-01-02 15:04"), host, snapshot.Subject)
+		if m.cursor == i {
+			s += selectedStyle.Render(line) + "\n"
+		} else {
+			s += line + "\n"
+		}
+	}
+
+	s += "\n"
+	diff := m.currentDiff()
+	end := min(m.diffOffset+m.diffHeight(), len(diff))
+	for _, line := range diff[m.diffOffset:end] {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			s += faintStyle.Render(line) + "\n"
+		case strings.HasPrefix(line, "+"):
+			s += addStyle.Render(line) + "\n"
+		case strings.HasPrefix(line, "-"):
+			s += delStyle.Render(line) + "\n"
+		default:
+			s += line + "\n"
+		}
+	}
+
+	if m.status != "" {
+		s += "\n" + style.Render(m.status) + "\n"
+	}
+	s += "\nPress q to quit\n"
+	return s
+}
@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hook events, also the names of the hook scripts in a repository
+const (
+	PreUpload   = "pre-upload"
+	PostUpload  = "post-upload"
+	PreInstall  = "pre-install"
+	PostInstall = "post-install"
+)
+
+// Hooks and scripts live in the repository next to the dotfiles. Upload
+// keeps this directory, it is no tracked path of its own.
+const (
+	HooksDir   = ".myd/hooks"
+	ScriptsDir = ".myd/scripts"
+)
+
+// HookEnv describes what a hook or script runs for
+type HookEnv struct {
+	Repo   string   // directory the dotfiles are in, hooks run inside it
+	Files  []string // files the command is about to change or changed
+	Target InstallTarget
+}
+
+func (e HookEnv) environ(event string) []string {
+	root := e.Target.Root
+	if root == "" {
+		root = "/"
+	}
+	env := append(os.Environ(),
+		"MYD_REPO="+e.Repo,
+		"MYD_FILES="+strings.Join(e.Files, "\n"),
+		"MYD_ROOT="+root,
+		"MYD_HOME="+e.Target.HomeDir(),
+	)
+	if event != "" {
+		env = append(env, "MYD_HOOK="+event)
+	}
+	return env
+}
+
+// configHooks returns the commands configured for event
+func configHooks(config *MydConfig, event string) []string {
+	switch event {
+	case PreUpload:
+		return config.Hooks.PreUpload
+	case PostUpload:
+		return config.Hooks.PostUpload
+	case PreInstall:
+		return config.Hooks.PreInstall
+	case PostInstall:
+		return config.Hooks.PostInstall
+	}
+	return nil
+}
+
+// RepoHooks lists the hook scripts of repo run for event, by name. A hook is
+// named after its event, optionally followed by - or . and anything else,
+// such as post-install-fonts.sh.
+func RepoHooks(repo, event string) []string {
+	var hooks []string
+	for _, path := range repoScriptFiles(filepath.Join(repo, HooksDir)) {
+		name := filepath.Base(path)
+		if name == event || strings.HasPrefix(name, event+"-") || strings.HasPrefix(name, event+".") {
+			hooks = append(hooks, path)
+		}
+	}
+	return hooks
+}
+
+// RepoScripts lists the run_once_ and run_onchange_ scripts of repo, by name
+func RepoScripts(repo string) []string {
+	var scripts []string
+	for _, path := range repoScriptFiles(filepath.Join(repo, ScriptsDir)) {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, "run_once_") || strings.HasPrefix(name, "run_onchange_") {
+			scripts = append(scripts, path)
+		}
+	}
+	return scripts
+}
+
+func repoScriptFiles(dir string) []string {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, dirEntry := range dirEntries {
+		if dirEntry.Type().IsRegular() {
+			paths = append(paths, filepath.Join(dir, dirEntry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// RunHooks runs the hooks of repo for event when repoHooks is set, then the
+// commands configured for it, stopping at the first one that fails
+func RunHooks(config *MydConfig, event string, env HookEnv, repoHooks bool) error {
+	if repoHooks {
+		for _, hook := range RepoHooks(env.Repo, event) {
+			Progress("Running %s hook %s\n", event, filepath.Base(hook))
+			if err := runScript(config, hook, env, event); err != nil {
+				return fmt.Errorf("%s hook %s failed: %v", event, filepath.Base(hook), err)
+			}
+		}
+	}
+	for _, command := range configHooks(config, event) {
+		Progress("Running %s hook %s\n", event, command)
+		if err := runHook(config, env, event, "sh", "-c", command); err != nil {
+			return fmt.Errorf("%s hook %q failed: %v", event, command, err)
+		}
+	}
+	return nil
+}
+
+// runScript runs a script directly when it is executable and through sh
+// otherwise, archives do not always keep the executable bit
+func runScript(config *MydConfig, path string, env HookEnv, event string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		return runHook(config, env, event, path)
+	}
+	return runHook(config, env, event, "sh", path)
+}
+
+func runHook(config *MydConfig, env HookEnv, event string, name string, args ...string) error {
+	ctx := context.Background()
+	if config.Hooks.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Hooks.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = env.Repo
+	cmd.Env = env.environ(event)
+	// Stdout stays free for --json
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("stopped after %s", config.Hooks.Timeout)
+	}
+	return err
+}
+
+// scriptStatePath returns the file recording which scripts ran on this machine
+func scriptStatePath(config *MydConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "scripts.txt")
+}
+
+// loadScriptState reads the hash each script of each source last ran with
+func loadScriptState(config *MydConfig) map[string]string {
+	state := make(map[string]string)
+	data, err := os.ReadFile(scriptStatePath(config))
+	if err != nil {
+		return state
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) == 3 {
+			state[parts[0]+"\t"+parts[1]] = parts[2]
+		}
+	}
+	return state
+}
+
+func saveScriptState(config *MydConfig, state map[string]string) error {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s\t%s\n", key, state[key])
+	}
+	return os.WriteFile(scriptStatePath(config), []byte(b.String()), 0644)
+}
+
+// PendingScripts lists the scripts of repo that RunScripts would run:
+// run_once_ scripts that never ran for source on this machine and
+// run_onchange_ scripts whose content changed since they last did
+func PendingScripts(config *MydConfig, source, repo string) ([]string, error) {
+	state := loadScriptState(config)
+	var pending []string
+	for _, script := range RepoScripts(repo) {
+		name := filepath.Base(script)
+		hash, err := FileHash(script)
+		if err != nil {
+			return nil, err
+		}
+		last, ran := state[source+"\t"+name]
+		if !ran || strings.HasPrefix(name, "run_onchange_") && last != hash {
+			pending = append(pending, script)
+		}
+	}
+	return pending, nil
+}
+
+// RunScripts runs the pending scripts of repo by name and records each one
+// that succeeds, stopping at the first failure so it is retried next time
+func RunScripts(config *MydConfig, source string, env HookEnv) error {
+	pending, err := PendingScripts(config, source, env.Repo)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	state := loadScriptState(config)
+	for _, script := range pending {
+		name := filepath.Base(script)
+		hash, err := FileHash(script)
+		if err != nil {
+			return err
+		}
+		Progress("Running script %s\n", name)
+		if err := runScript(config, script, env, ""); err != nil {
+			return fmt.Errorf("script %s failed: %v", name, err)
+		}
+		state[source+"\t"+name] = hash
+		if err := saveScriptState(config, state); err != nil {
+			return fmt.Errorf("failed to record that %s ran: %v", name, err)
+		}
+	}
+	return nil
+}
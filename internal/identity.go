@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// Identity is the author and committer used for upload commits
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Env returns the git environment variables for the identity
+func (id Identity) Env() []string {
+	return []string{
+		"GIT_AUTHOR_NAME=" + id.Name,
+		"GIT_AUTHOR_EMAIL=" + id.Email,
+		"GIT_COMMITTER_NAME=" + id.Name,
+		"GIT_COMMITTER_EMAIL=" + id.Email,
+	}
+}
+
+var fallbackIdentity = Identity{Name: "myd", Email: "myd@local"}
+
+// ResolveIdentity picks the commit identity according to commit.identity.
+// "auto" tries the config, then the global git config, then the GitHub account.
+func ResolveIdentity(config *MydConfig, ghUser *github.User) (Identity, error) {
+	var sources []func() Identity
+	switch config.CommitIdentity {
+	case "config":
+		sources = append(sources, func() Identity { return configIdentity(config) })
+	case "git":
+		sources = append(sources, globalGitIdentity)
+	case "github":
+		sources = append(sources, func() Identity { return githubIdentity(ghUser) })
+	case "auto", "":
+		sources = append(sources,
+			func() Identity { return configIdentity(config) },
+			globalGitIdentity,
+			func() Identity { return githubIdentity(ghUser) },
+		)
+	default:
+		return Identity{}, fmt.Errorf("unknown commit.identity %q, expected auto, config, git or github", config.CommitIdentity)
+	}
+
+	for _, source := range sources {
+		if id := source(); id.Name != "" && id.Email != "" {
+			return id, nil
+		}
+	}
+	return fallbackIdentity, nil
+}
+
+func configIdentity(config *MydConfig) Identity {
+	return Identity{Name: config.CommitName, Email: config.CommitEmail}
+}
+
+func globalGitIdentity() Identity {
+	return Identity{
+		Name:  globalGitConfig("user.name"),
+		Email: globalGitConfig("user.email"),
+	}
+}
+
+func githubIdentity(ghUser *github.User) Identity {
+	if ghUser == nil || ghUser.GetLogin() == "" {
+		return Identity{}
+	}
+
+	name := ghUser.GetName()
+	if name == "" {
+		name = ghUser.GetLogin()
+	}
+
+	// Fall back to the noreply address so commits still link to the account
+	email := ghUser.GetEmail()
+	if email == "" {
+		email = fmt.Sprintf("%d+%s@users.noreply.github.com", ghUser.GetID(), ghUser.GetLogin())
+	}
+	return Identity{Name: name, Email: email}
+}
+
+// globalGitConfig reads a key from the user's global git config
+func globalGitConfig(key string) string {
+	output, err := exec.Command("git", "config", "--global", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// SigningArgs returns the git arguments needed to sign a commit.
+// The first slice goes before the git subcommand, the second after it.
+func SigningArgs(config *MydConfig) ([]string, []string, error) {
+	if !config.Signing.Enabled {
+		return nil, []string{"--no-gpg-sign"}, nil
+	}
+
+	key := config.Signing.Key
+	if key == "" {
+		key = globalGitConfig("user.signingkey")
+	}
+
+	switch config.Signing.Format {
+	case "gpg", "openpgp", "":
+		globalArgs := []string{"-c", "gpg.format=openpgp"}
+		if key == "" {
+			// gpg picks the key matching the committer email
+			return globalArgs, []string{"-S"}, nil
+		}
+		return globalArgs, []string{"-S" + key}, nil
+	case "ssh":
+		if key == "" {
+			return nil, nil, fmt.Errorf("signing.key is required for ssh signing")
+		}
+		return []string{"-c", "gpg.format=ssh", "-c", "user.signingkey=" + key}, []string{"-S"}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown signing.format %q, expected gpg or ssh", config.Signing.Format)
+	}
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ignoreRule is a single parsed line in gitignore syntax
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides whether a path inside a tracked directory is ignored,
+// following gitignore semantics: the last matching rule wins, "!" re-includes
+// and nothing inside an ignored directory can be re-included.
+type Matcher struct {
+	rules []ignoreRule
+}
+
+// NewMatcher parses patterns written in gitignore syntax
+func NewMatcher(patterns []string) *Matcher {
+	m := &Matcher{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\`) {
+			// \! and \# escape a literal first character
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+
+		// Patterns without a slash match at any depth, others are anchored
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+		if pattern == "" {
+			continue
+		}
+
+		rule.pattern = pattern
+		m.rules = append(m.rules, rule)
+	}
+	return m
+}
+
+// Ignored reports whether rel, a slash separated path relative to the
+// tracked directory, is excluded
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+
+	if _, ok := m.IgnoredAncestor(rel); ok {
+		return true
+	}
+	return m.match(strings.Trim(filepath.ToSlash(rel), "/"), isDir)
+}
+
+// IgnoredAncestor returns the closest parent directory of rel that is ignored
+func (m *Matcher) IgnoredAncestor(rel string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	segments := strings.Split(strings.Trim(filepath.ToSlash(rel), "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if m.match(strings.Join(segments[:i], "/"), true) {
+			return strings.Join(segments[:i], "/"), true
+		}
+	}
+	return "", false
+}
+
+func (m *Matcher) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if MatchGlob(rule.pattern, rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// IgnoreRules maps a tracked path or pattern to its ignore patterns
+type IgnoreRules map[string][]string
+
+// IgnoreFilePath returns the location of the ignore rules
+func IgnoreFilePath(config *MydConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "ignore.txt")
+}
+
+// LoadIgnoreRules reads ignore.txt, where each "[tracked path]" header is
+// followed by the gitignore style patterns for that path. The patterns from
+// the config apply to every tracked path and are kept under the "" scope.
+func LoadIgnoreRules(config *MydConfig) (IgnoreRules, error) {
+	rules := make(IgnoreRules)
+	if len(config.IgnorePatterns) > 0 {
+		rules[""] = append([]string(nil), config.IgnorePatterns...)
+	}
+
+	file, err := os.Open(IgnoreFilePath(config))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scope := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			scope = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if scope == "" {
+			return nil, fmt.Errorf("ignore.txt:%d: rule %q is not under a [tracked path] header", lineNumber, line)
+		}
+		rules[scope] = append(rules[scope], line)
+	}
+	return rules, scanner.Err()
+}
+
+// SaveIgnoreRules writes the rules back to ignore.txt
+func SaveIgnoreRules(config *MydConfig, rules IgnoreRules) error {
+	scopes := make([]string, 0, len(rules))
+	for scope, patterns := range rules {
+		if scope != "" && len(patterns) > 0 {
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+
+	var b strings.Builder
+	b.WriteString("# Ignore rules in gitignore syntax, grouped by tracked path\n")
+	for _, scope := range scopes {
+		fmt.Fprintf(&b, "\n[%s]\n", scope)
+		for _, pattern := range rules[scope] {
+			b.WriteString(pattern + "\n")
+		}
+	}
+
+	path := IgnoreFilePath(config)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// Matcher returns the matcher for a tracked path
+func (rules IgnoreRules) Matcher(tracked string) *Matcher {
+	patterns := append([]string(nil), rules[""]...)
+	return NewMatcher(append(patterns, rules[tracked]...))
+}
+
+// Add appends a pattern for a tracked path, reporting false if it was already present
+func (rules IgnoreRules) Add(tracked, pattern string) bool {
+	for _, existing := range rules[tracked] {
+		if existing == pattern {
+			return false
+		}
+	}
+	rules[tracked] = append(rules[tracked], pattern)
+	return true
+}
+
+// Remove deletes a pattern for a tracked path, reporting whether it was present
+func (rules IgnoreRules) Remove(tracked, pattern string) bool {
+	for i, existing := range rules[tracked] {
+		if existing == pattern {
+			rules[tracked] = append(rules[tracked][:i], rules[tracked][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Ignore excludes rel, a path relative to the tracked directory. A negation
+// for the path is dropped first, and a rule is only added when still needed.
+func (rules IgnoreRules) Ignore(tracked, rel string, isDir bool) {
+	rule := "/" + filepath.ToSlash(rel)
+	rules.Remove(tracked, "!"+rule)
+	if !rules.Matcher(tracked).Ignored(rel, isDir) {
+		rules.Add(tracked, rule)
+	}
+}
+
+// Unignore re-includes rel. The exact rule is dropped first, and when a
+// broader pattern still matches a negation is added. Paths inside an ignored
+// directory cannot be re-included, as in git.
+func (rules IgnoreRules) Unignore(tracked, rel string, isDir bool) error {
+	rule := "/" + filepath.ToSlash(rel)
+	rules.Remove(tracked, rule)
+	rules.Remove(tracked, rule+"/")
+
+	matcher := rules.Matcher(tracked)
+	if !matcher.Ignored(rel, isDir) {
+		return nil
+	}
+	if parent, ok := matcher.IgnoredAncestor(rel); ok {
+		return fmt.Errorf("%s is inside the ignored directory %s, unignore that first", rel, parent)
+	}
+	rules.Add(tracked, "!"+rule)
+	return nil
+}
+
+// TrackedRoot returns the directory a tracked path or pattern is rooted at
+func TrackedRoot(tracked string) string {
+	if IsGlob(tracked) {
+		return GlobBase(tracked)
+	}
+	return tracked
+}
+
+// ContainingTracked finds the tracked path with the deepest root that contains path
+func ContainingTracked(tracked []string, path string) (string, bool) {
+	best, found := "", false
+	for _, candidate := range tracked {
+		root := TrackedRoot(candidate)
+		if path != root && !strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(root) > len(TrackedRoot(best)) {
+			best, found = candidate, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,367 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ignoreNode is a file or directory inside the tracked directory being edited
+type ignoreNode struct {
+	name     string
+	rel      string
+	isDir    bool
+	size     int64
+	depth    int
+	expanded bool
+	parent   *ignoreNode
+	children []*ignoreNode
+}
+
+// IgnoreModel is a tree view of a tracked directory where files can be
+// toggled between included and ignored
+type IgnoreModel struct {
+	config   *MydConfig
+	rules    IgnoreRules
+	tracked  []string // tracked directories that can be browsed
+	choosing bool
+	choice   int
+
+	trackedPath string
+	root        *ignoreNode
+	matcher     *Matcher
+	uploadSize  map[*ignoreNode]int64 // size each subtree adds to the upload
+	totalSize   map[*ignoreNode]int64
+	visible     []*ignoreNode
+
+	cursor   int
+	offset   int
+	height   int
+	status   string
+	quitting bool
+}
+
+// NewIgnoreModel opens the tree view for trackedPath, or a chooser over all
+// tracked directories when trackedPath is empty
+func NewIgnoreModel(config *MydConfig, trackedPath string) (*IgnoreModel, error) {
+	rules, err := LoadIgnoreRules(config)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &IgnoreModel{config: config, rules: rules, height: 24}
+	for _, path := range LoadTrackedPaths(config) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() && !IsGlob(path) {
+			m.tracked = append(m.tracked, path)
+		}
+	}
+
+	switch {
+	case trackedPath != "":
+		return m, m.open(trackedPath)
+	case len(m.tracked) == 1:
+		return m, m.open(m.tracked[0])
+	case len(m.tracked) == 0:
+		return nil, fmt.Errorf("no tracked directories to ignore files in")
+	}
+	m.choosing = true
+	return m, nil
+}
+
+// open loads the tree of a tracked directory
+func (m *IgnoreModel) open(trackedPath string) error {
+	root := &ignoreNode{name: filepath.Base(trackedPath), isDir: true, expanded: true}
+	if err := loadIgnoreTree(trackedPath, root); err != nil {
+		return err
+	}
+
+	m.trackedPath = trackedPath
+	m.root = root
+	m.cursor, m.offset = 0, 0
+	m.choosing = false
+	m.refresh()
+	return nil
+}
+
+func loadIgnoreTree(dir string, node *ignoreNode) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		child := &ignoreNode{
+			name:   entry.Name(),
+			rel:    filepath.ToSlash(filepath.Join(node.rel, entry.Name())),
+			isDir:  entry.IsDir(),
+			size:   info.Size(),
+			depth:  node.depth + 1,
+			parent: node,
+		}
+		if child.isDir {
+			child.size = 0
+			// Unreadable directories are shown empty rather than failing the view
+			loadIgnoreTree(filepath.Join(dir, entry.Name()), child)
+		}
+		node.children = append(node.children, child)
+	}
+
+	// Directories first, then files, each alphabetically
+	sort.Slice(node.children, func(i, j int) bool {
+		a, b := node.children[i], node.children[j]
+		if a.isDir != b.isDir {
+			return a.isDir
+		}
+		return a.name < b.name
+	})
+	return nil
+}
+
+// refresh recomputes sizes and the visible rows after a change
+func (m *IgnoreModel) refresh() {
+	m.matcher = m.rules.Matcher(m.trackedPath)
+	m.uploadSize = make(map[*ignoreNode]int64)
+	m.totalSize = make(map[*ignoreNode]int64)
+	m.measure(m.root, false)
+
+	m.visible = m.visible[:0]
+	for _, child := range m.root.children {
+		m.flatten(child)
+	}
+	if m.cursor >= len(m.visible) {
+		m.cursor = max(len(m.visible)-1, 0)
+	}
+}
+
+func (m *IgnoreModel) measure(node *ignoreNode, parentIgnored bool) {
+	ignored := parentIgnored || (node.rel != "" && m.matcher.Ignored(node.rel, node.isDir))
+
+	total, upload := node.size, node.size
+	for _, child := range node.children {
+		m.measure(child, ignored)
+		total += m.totalSize[child]
+		upload += m.uploadSize[child]
+	}
+	if ignored {
+		upload = 0
+	}
+	m.totalSize[node] = total
+	m.uploadSize[node] = upload
+}
+
+func (m *IgnoreModel) flatten(node *ignoreNode) {
+	m.visible = append(m.visible, node)
+	if node.isDir && node.expanded {
+		for _, child := range node.children {
+			m.flatten(child)
+		}
+	}
+}
+
+func (m *IgnoreModel) ignored(node *ignoreNode) bool {
+	return m.matcher.Ignored(node.rel, node.isDir)
+}
+
+// toggle flips the node between included and ignored and saves the rules
+func (m *IgnoreModel) toggle(node *ignoreNode) {
+	if m.ignored(node) {
+		if err := m.rules.Unignore(m.trackedPath, node.rel, node.isDir); err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.status = fmt.Sprintf("Including %s", node.rel)
+	} else {
+		m.rules.Ignore(m.trackedPath, node.rel, node.isDir)
+		m.status = fmt.Sprintf("Ignoring %s", node.rel)
+	}
+
+	if err := SaveIgnoreRules(m.config, m.rules); err != nil {
+		m.status = fmt.Sprintf("Failed to save ignore rules: %v", err)
+	}
+	m.refresh()
+}
+
+func (m *IgnoreModel) listHeight() int {
+	return max(m.height-7, 3)
+}
+
+func (m *IgnoreModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *IgnoreModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if m.choosing {
+			return m.updateChooser(msg)
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			if len(m.tracked) > 1 {
+				m.choosing = true
+				m.status = ""
+			}
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.visible)-1 {
+				m.cursor++
+			}
+		case "right", "l", "enter":
+			if len(m.visible) > 0 && m.visible[m.cursor].isDir {
+				m.visible[m.cursor].expanded = true
+				m.refresh()
+			}
+		case "left", "h":
+			if len(m.visible) == 0 {
+				break
+			}
+			node := m.visible[m.cursor]
+			if node.isDir && node.expanded {
+				node.expanded = false
+			} else if node.parent != nil && node.parent != m.root {
+				node.parent.expanded = false
+				node = node.parent
+			}
+			m.refresh()
+			for i, visible := range m.visible {
+				if visible == node {
+					m.cursor = i
+				}
+			}
+		case " ":
+			if len(m.visible) > 0 {
+				m.toggle(m.visible[m.cursor])
+			}
+		}
+
+		// Keep the cursor inside the viewport
+		if m.cursor < m.offset {
+			m.offset = m.cursor
+		} else if m.cursor >= m.offset+m.listHeight() {
+			m.offset = m.cursor - m.listHeight() + 1
+		}
+	}
+	return m, nil
+}
+
+func (m *IgnoreModel) updateChooser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.choice > 0 {
+			m.choice--
+		}
+	case "down", "j":
+		if m.choice < len(m.tracked)-1 {
+			m.choice++
+		}
+	case "enter", " ":
+		if err := m.open(m.tracked[m.choice]); err != nil {
+			m.status = err.Error()
+		}
+	}
+	return m, nil
+}
+
+func (m *IgnoreModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	faintStyle := lipgloss.NewStyle().Faint(true)
+
+	if m.choosing {
+		s := "Select a tracked directory (enter to open):\n\n"
+		for i, path := range m.tracked {
+			cursor := " "
+			if m.choice == i {
+				cursor = ">"
+			}
+			line := fmt.Sprintf("%s %s", cursor, path)
+			if m.choice == i {
+				s += selectedStyle.Render(line) + "\n"
+			} else {
+				s += line + "\n"
+			}
+		}
+		if m.status != "" {
+			s += "\n" + style.Render(m.status) + "\n"
+		}
+		return s + "\nPress q to quit\n"
+	}
+
+	s := fmt.Sprintf("%s uploads %s of %s (space to toggle, arrows to browse):\n\n",
+		m.trackedPath, FormatSize(m.uploadSize[m.root]), FormatSize(m.totalSize[m.root]))
+
+	if len(m.visible) == 0 {
+		s += "  (empty)\n"
+	}
+
+	end := min(m.offset+m.listHeight(), len(m.visible))
+	for i := m.offset; i < end; i++ {
+		node := m.visible[i]
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+
+		checked := "x"
+		if m.ignored(node) {
+			checked = " "
+		}
+
+		arrow := " "
+		name := node.name
+		if node.isDir {
+			arrow = "▸"
+			if node.expanded {
+				arrow = "▾"
+			}
+			name += "/"
+		}
+
+		size := FormatSize(m.uploadSize[node])
+		if checked == " " {
+			size = "ignored, " + FormatSize(m.totalSize[node])
+		}
+
+		line := fmt.Sprintf("%s %s[%s] %s %s", cursor, strings.Repeat("  ", node.depth-1), checked, arrow, name)
+		switch {
+		case m.cursor == i:
+			s += selectedStyle.Render(line) + "  " + faintStyle.Render(size) + "\n"
+		case checked == " ":
+			s += faintStyle.Render(line+"  "+size) + "\n"
+		default:
+			s += line + "  " + faintStyle.Render(size) + "\n"
+		}
+	}
+
+	if m.status != "" {
+		s += "\n" + style.Render(m.status) + "\n"
+	}
+	s += "\nPress q to quit\n"
+	return s
+}
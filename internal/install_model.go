@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// InstallModel lets the user pick which entries of a repository to install
+type InstallModel struct {
+	entries  []ManifestEntry
+	dests    map[string]string
+	exists   map[string]bool
+	refused  map[string]error // entries install would refuse, e.g. outside the path roots
+	names    []string
+	filter   string
+	visible  []string // entry names matching the filter, best match first
+	selected map[string]bool
+
+	cursor    int
+	offset    int
+	height    int
+	status    string
+	confirmed bool
+	quitting  bool
+}
+
+// NewInstallModel lists entries with the location each one installs to in
+// target, every entry install accepts starts selected
+func NewInstallModel(entries []ManifestEntry, target InstallTarget) *InstallModel {
+	m := &InstallModel{
+		entries:  entries,
+		dests:    make(map[string]string),
+		exists:   make(map[string]bool),
+		refused:  make(map[string]error),
+		selected: make(map[string]bool),
+		height:   24,
+	}
+	for _, entry := range entries {
+		m.names = append(m.names, entry.Name)
+		dest, err := entry.InstallDestIn(target)
+		if err != nil {
+			m.dests[entry.Name] = entry.Target
+			m.refused[entry.Name] = err
+			continue
+		}
+		if entry.Pattern != "" {
+			// Show where the matches go rather than their whole base
+			dest = filepath.Join(dest, globRest(entry.Pattern))
+			matches, _ := ExpandGlob(dest)
+			m.exists[entry.Name] = len(matches) > 0
+		} else {
+			_, err = os.Lstat(dest)
+			m.exists[entry.Name] = err == nil
+		}
+		m.dests[entry.Name] = dest
+		m.selected[entry.Name] = true
+	}
+	m.applyFilter()
+	return m
+}
+
+// Chosen returns the selected entries in repository order, or nil when the
+// chooser was cancelled
+func (m *InstallModel) Chosen() []ManifestEntry {
+	if !m.confirmed {
+		return nil
+	}
+	var chosen []ManifestEntry
+	for _, entry := range m.entries {
+		if m.selected[entry.Name] {
+			chosen = append(chosen, entry)
+		}
+	}
+	return chosen
+}
+
+func (m *InstallModel) applyFilter() {
+	m.visible = FuzzyFilter(m.filter, m.names)
+	m.cursor, m.offset = 0, 0
+}
+
+func (m *InstallModel) count() int {
+	count := 0
+	for _, name := range m.names {
+		if m.selected[name] {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *InstallModel) listHeight() int {
+	return max(m.height-8, 3)
+}
+
+func (m *InstallModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *InstallModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		m.status = ""
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyEsc:
+			// The first escape clears the filter
+			if m.filter == "" {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.filter = ""
+			m.applyFilter()
+		case tea.KeyUp:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown:
+			if m.cursor < len(m.visible)-1 {
+				m.cursor++
+			}
+		case tea.KeyPgUp:
+			m.cursor = max(m.cursor-m.listHeight(), 0)
+		case tea.KeyPgDown:
+			m.cursor = max(min(m.cursor+m.listHeight(), len(m.visible)-1), 0)
+		case tea.KeySpace:
+			if len(m.visible) > 0 {
+				name := m.visible[m.cursor]
+				if err := m.refused[name]; err != nil {
+					m.status = err.Error()
+					break
+				}
+				m.selected[name] = !m.selected[name]
+			}
+		case tea.KeyCtrlA:
+			for _, name := range m.visible {
+				m.selected[name] = m.refused[name] == nil
+			}
+		case tea.KeyCtrlR:
+			for _, name := range m.visible {
+				m.selected[name] = !m.selected[name] && m.refused[name] == nil
+			}
+		case tea.KeyEnter:
+			if m.count() == 0 {
+				m.status = "Nothing selected"
+				break
+			}
+			m.confirmed, m.quitting = true, true
+			return m, tea.Quit
+		case tea.KeyBackspace:
+			if m.filter != "" {
+				runes := []rune(m.filter)
+				m.filter = string(runes[:len(runes)-1])
+				m.applyFilter()
+			}
+		case tea.KeyRunes:
+			m.filter += string(msg.Runes)
+			m.applyFilter()
+		}
+
+		// Keep the cursor inside the viewport
+		if m.cursor < m.offset {
+			m.offset = m.cursor
+		} else if m.cursor >= m.offset+m.listHeight() {
+			m.offset = m.cursor - m.listHeight() + 1
+		}
+	}
+	return m, nil
+}
+
+func (m *InstallModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	selectedStyle := style.Copy().Bold(true)
+	faintStyle := lipgloss.NewStyle().Faint(true)
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
+	s := "Select entries to install (type to filter, space to toggle, enter to install):\n\n"
+	s += "> " + m.filter + faintStyle.Render(fmt.Sprintf("  %d/%d", len(m.visible), len(m.names))) + "\n\n"
+	if len(m.visible) == 0 {
+		s += "  (no matches)\n"
+	}
+
+	width := 0
+	for _, name := range m.visible {
+		width = max(width, len(name))
+	}
+
+	end := min(m.offset+m.listHeight(), len(m.visible))
+	for i := m.offset; i < end; i++ {
+		name := m.visible[i]
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		checked := " "
+		if m.selected[name] {
+			checked = "x"
+		}
+
+		line := fmt.Sprintf("%s [%s] %-*s  ", cursor, checked, width, name)
+		if m.cursor == i {
+			line = selectedStyle.Render(line)
+		}
+		line += faintStyle.Render(m.dests[name])
+		if m.refused[name] != nil {
+			line += " " + warnStyle.Render("(refused, space shows why)")
+		} else if m.exists[name] {
+			line += " " + warnStyle.Render("(exists, will be overwritten)")
+		}
+		s += line + "\n"
+	}
+
+	s += fmt.Sprintf("\n%d of %d selected\n", m.count(), len(m.names))
+	if m.status != "" {
+		s += "\n" + style.Render(m.status) + "\n"
+	}
+	s += "\n" + faintStyle.Render("ctrl+a select all  ctrl+r invert  esc cancel") + "\n"
+	return s
+}
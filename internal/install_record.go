@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InstalledFile is a file written by install
+type InstalledFile struct {
+	Path   string
+	Hash   string // sha256 of what install wrote
+	Backup string // file in the record's backup directory holding the original, empty if there was none
+}
+
+// InstallRecord lists what installing one source wrote, so uninstall can
+// take it back and myd installed can tell what changed since. Each source
+// keeps its record in installs/<id>/record.txt, next to a backups
+// directory with the files it replaced.
+type InstallRecord struct {
+	Source string
+	URL    string    // where the source was read from, a clone URL or a local path
+	Commit string    // commit the source was at, empty when it is not a git repository
+	Time   time.Time // last install
+	Files  []InstalledFile
+	Dirs   []string // directories install created, in creation order
+
+	dir string
+}
+
+// InstallsDir returns the directory holding the install records
+func InstallsDir(config *MydConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "installs")
+}
+
+func installRecordDir(config *MydConfig, source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(InstallsDir(config), hex.EncodeToString(sum[:8]))
+}
+
+// OpenInstallRecord returns the record of source, empty when it was never
+// installed
+func OpenInstallRecord(config *MydConfig, source string) (*InstallRecord, error) {
+	record, err := readInstallRecord(installRecordDir(config, source))
+	if os.IsNotExist(err) {
+		return &InstallRecord{Source: source, dir: installRecordDir(config, source)}, nil
+	}
+	return record, err
+}
+
+// LoadInstallRecords returns the record of every installed source
+func LoadInstallRecords(config *MydConfig) ([]*InstallRecord, error) {
+	dirEntries, err := os.ReadDir(InstallsDir(config))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*InstallRecord
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		record, err := readInstallRecord(filepath.Join(InstallsDir(config), dirEntry.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readInstallRecord(dir string) (*InstallRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "record.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	record := &InstallRecord{dir: dir}
+	for lineNumber, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		switch {
+		case parts[0] == "source" && len(parts) == 2:
+			record.Source = parts[1]
+		case parts[0] == "url" && len(parts) == 2:
+			record.URL = parts[1]
+		case parts[0] == "commit" && len(parts) == 2:
+			record.Commit = parts[1]
+		case parts[0] == "time" && len(parts) == 2:
+			if record.Time, err = time.Parse(time.RFC3339, parts[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid time %q", filepath.Join(dir, "record.txt"), lineNumber+1, parts[1])
+			}
+		case parts[0] == "dir" && len(parts) == 2:
+			record.Dirs = append(record.Dirs, parts[1])
+		case parts[0] == "file" && len(parts) == 4:
+			record.Files = append(record.Files, InstalledFile{Hash: parts[1], Backup: parts[2], Path: parts[3]})
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected %q", filepath.Join(dir, "record.txt"), lineNumber+1, line)
+		}
+	}
+	return record, nil
+}
+
+// Save writes the record, removing it once nothing is left to undo
+func (r *InstallRecord) Save() error {
+	if len(r.Files) == 0 && len(r.Dirs) == 0 {
+		return os.RemoveAll(r.dir)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "source\t%s\n", r.Source)
+	if r.URL != "" {
+		fmt.Fprintf(&b, "url\t%s\n", r.URL)
+	}
+	if r.Commit != "" {
+		fmt.Fprintf(&b, "commit\t%s\n", r.Commit)
+	}
+	if !r.Time.IsZero() {
+		fmt.Fprintf(&b, "time\t%s\n", r.Time.Format(time.RFC3339))
+	}
+	for _, dir := range r.Dirs {
+		fmt.Fprintf(&b, "dir\t%s\n", dir)
+	}
+	for _, file := range r.Files {
+		fmt.Fprintf(&b, "file\t%s\t%s\t%s\n", file.Hash, file.Backup, file.Path)
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, "record.txt"), []byte(b.String()), 0644)
+}
+
+func (r *InstallRecord) file(path string) *InstalledFile {
+	for i := range r.Files {
+		if r.Files[i].Path == path {
+			return &r.Files[i]
+		}
+	}
+	return nil
+}
+
+// CreatedDirs records directories made by install
+func (r *InstallRecord) CreatedDirs(dirs ...string) {
+	for _, dir := range dirs {
+		known := false
+		for _, existing := range r.Dirs {
+			known = known || existing == dir
+		}
+		if !known {
+			r.Dirs = append(r.Dirs, dir)
+		}
+	}
+}
+
+// Prepare is called before install writes path and backs up what is there.
+// Files from an earlier install of the same source keep their first backup.
+func (r *InstallRecord) Prepare(path string) error {
+	if r.file(path) != nil {
+		return nil
+	}
+
+	file := InstalledFile{Path: path}
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	default:
+		sum := sha256.Sum256([]byte(path))
+		file.Backup = hex.EncodeToString(sum[:8])
+		if err := copyPreserving(path, filepath.Join(r.dir, "backups", file.Backup)); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", path, err)
+		}
+	}
+	r.Files = append(r.Files, file)
+	return nil
+}
+
+// Installed is called after install wrote path and records its hash
+func (r *InstallRecord) Installed(path string) error {
+	hash, err := FileHash(path)
+	if err != nil {
+		return err
+	}
+	if file := r.file(path); file != nil {
+		file.Hash = hash
+		return nil
+	}
+	r.Files = append(r.Files, InstalledFile{Path: path, Hash: hash})
+	return nil
+}
+
+// State compares a file with what install wrote: installed, modified or missing
+func (f InstalledFile) State() (string, error) {
+	hash, err := FileHash(f.Path)
+	switch {
+	case os.IsNotExist(err):
+		return "missing", nil
+	case err != nil:
+		return "", err
+	case hash != f.Hash:
+		return "modified", nil
+	}
+	return "installed", nil
+}
+
+// Uninstall removes the installed files and puts the backups back. Files
+// changed since install are left alone and stay in the record unless
+// force is set. It returns the files that were kept.
+func (r *InstallRecord) Uninstall(force bool) ([]string, error) {
+	var kept []InstalledFile
+	for i := len(r.Files) - 1; i >= 0; i-- {
+		file := r.Files[i]
+		hash, err := FileHash(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if hash != file.Hash && !force {
+			kept = append([]InstalledFile{file}, kept...)
+			continue
+		}
+
+		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if file.Backup != "" {
+			if err := copyPreserving(filepath.Join(r.dir, "backups", file.Backup), file.Path); err != nil {
+				return nil, fmt.Errorf("failed to restore %s: %v", file.Path, err)
+			}
+		}
+	}
+
+	// Directories go last and only when nothing else ended up in them
+	var dirs []string
+	for i := len(r.Dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(r.Dirs[i]); err != nil && !os.IsNotExist(err) {
+			dirs = append([]string{r.Dirs[i]}, dirs...)
+		}
+	}
+
+	r.Files = kept
+	if len(kept) == 0 {
+		dirs = nil
+	}
+	r.Dirs = dirs
+
+	var paths []string
+	for _, file := range kept {
+		paths = append(paths, file.Path)
+	}
+	return paths, r.Save()
+}
+
+// FileHash returns the sha256 of a file's content. Symlinks are followed,
+// so a change behind a link shows up as well.
+func FileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// copyPreserving copies a file or symlink with its mode and, as far as
+// permissions allow, its owner
+func copyPreserving(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	os.Remove(dst)
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if owner, err := FileOwner(src); err == nil {
+		chown(dst, owner)
+	}
+	return nil
+}
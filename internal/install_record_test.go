@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstalledFileStateFollowsLinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "bashrc")
+	link := filepath.Join(dir, ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("installed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	record := &InstallRecord{dir: filepath.Join(dir, "record")}
+	if err := record.Installed(link); err != nil {
+		t.Fatal(err)
+	}
+	file := record.Files[0]
+
+	for _, step := range []struct {
+		change func() error
+		want   string
+	}{
+		{func() error { return nil }, "installed"},
+		{func() error { return os.WriteFile(target, []byte("edited\n"), 0644) }, "modified"},
+		{func() error { return os.Remove(target) }, "missing"},
+	} {
+		if err := step.change(); err != nil {
+			t.Fatal(err)
+		}
+		if state, err := file.State(); err != nil || state != step.want {
+			t.Errorf("State() = %q, %v, want %q", state, err, step.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestEntry is a tracked path as stored in a dotfiles repository
+type ManifestEntry struct {
+	Name    string // file or directory name inside the repository
+	Target  string // original location, may contain environment variables
+	IsDir   bool
+	Pattern string // for the matches of a tracked pattern, Target is then its base
+}
+
+// Contains reports whether a path relative to the entry's destination
+// belongs to it. Pattern entries only own their matches.
+func (e ManifestEntry) Contains(rel string) bool {
+	return e.Pattern == "" || MatchGlobBelow(e.Pattern, rel)
+}
+
+// Dest returns the expanded location of the entry, for matching and display
+func (e ManifestEntry) Dest() string {
+	if dest, err := e.InstallDest(); err == nil {
+		return dest
+	}
+	return os.ExpandEnv(e.Target)
+}
+
+// InstallDest returns the location the entry installs to, refusing targets
+// outside the path roots
+func (e ManifestEntry) InstallDest() (string, error) {
+	return e.InstallDestIn(InstallTarget{})
+}
+
+// InstallDestIn is InstallDest for a remapped target
+func (e ManifestEntry) InstallDestIn(target InstallTarget) (string, error) {
+	config := GetGlobalConfig()
+	if config == nil {
+		config = &MydConfig{}
+	}
+	return target.Resolve(config, e.Target)
+}
+
+// ReadManifest collects the entries of a repository checked out at dir.
+// Single files are listed in the root .original_path, directories carry
+// their own .original_path, holding the pattern for the matches of one.
+func ReadManifest(dir string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	if data, err := os.ReadFile(filepath.Join(dir, ".original_path")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			entries = append(entries, ManifestEntry{Name: filepath.Base(line), Target: line})
+		}
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() || dirEntry.Name() == ".git" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, dirEntry.Name(), ".original_path"))
+		if err != nil {
+			continue
+		}
+		target := strings.TrimSpace(string(data))
+		if target == "" {
+			continue
+		}
+		entry := ManifestEntry{Name: dirEntry.Name(), Target: target, IsDir: true}
+		if IsGlob(target) {
+			entry.Pattern, entry.Target = target, GlobBase(target)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// FindEntry finds the entry a path belongs to. The path may be an entry name,
+// an original location or a path inside a tracked directory, in which case
+// the part relative to the entry is returned as sub.
+func FindEntry(entries []ManifestEntry, path string) (entry ManifestEntry, sub string, ok bool) {
+	for _, entry := range entries {
+		if entry.Name == path {
+			return entry, "", true
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ManifestEntry{}, "", false
+	}
+	for _, entry := range entries {
+		dest := entry.Dest()
+		if absPath == dest {
+			return entry, "", true
+		}
+		if entry.IsDir && strings.HasPrefix(absPath, dest+string(filepath.Separator)) {
+			rel, _ := filepath.Rel(dest, absPath)
+			if entry.Contains(rel) {
+				return entry, rel, true
+			}
+		}
+	}
+	return ManifestEntry{}, "", false
+}
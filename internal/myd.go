@@ -8,9 +8,27 @@ import (
 	"time"
 )
 
-func Exit(msg string,err error) {
+// Quiet silences progress messages and warnings, for commands whose output is the result itself
+var Quiet bool
+
+// Progress prints a progress message to stderr unless Quiet is set, so
+// stdout only carries results
+func Progress(format string, a ...interface{}) {
+	if !Quiet {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
+// Warn prints a warning to stderr unless Quiet is set
+func Warn(format string, a ...interface{}) {
+	if !Quiet {
+		fmt.Fprintf(os.Stderr, "Warning: "+format, a...)
+	}
+}
+
+func Exit(msg string, err error) {
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 	if msg != "" {
@@ -19,6 +37,20 @@ func Exit(msg string,err error) {
 	os.Exit(0)
 }
 
+// FormatSize renders a byte count in a human readable form
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // LogData logs the input data into a specified log file with the format [LOG] time lineNumber: logData
 func Log(data interface{}, logFile string) error {
 	// Open or create the log file
@@ -49,4 +81,4 @@ func Log(data interface{}, logFile string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Owner is the user and group installed files are handed to
+type Owner struct {
+	UID int
+	GID int
+}
+
+// MkdirAllAs creates dir and any missing parents like os.MkdirAll, handing
+// the directories it created to owner when one is given. It returns the
+// created directories, outermost first.
+func MkdirAllAs(dir string, owner *Owner) ([]string, error) {
+	var missing []string
+	for path := filepath.Clean(dir); ; path = filepath.Dir(path) {
+		if _, err := os.Lstat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+		missing = append([]string{path}, missing...)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return missing, nil
+	}
+	for _, path := range missing {
+		if err := chown(path, owner); err != nil {
+			return missing, err
+		}
+	}
+	return missing, nil
+}
+
+// Chown hands paths to owner, without following symlinks
+func Chown(owner *Owner, paths ...string) error {
+	if owner == nil {
+		return nil
+	}
+	for _, path := range paths {
+		if err := chown(path, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
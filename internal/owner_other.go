@@ -0,0 +1,14 @@
+//go:build !unix
+
+package internal
+
+import "fmt"
+
+// FileOwner is not supported without unix file ownership
+func FileOwner(path string) (*Owner, error) {
+	return nil, fmt.Errorf("file ownership is not supported on this system")
+}
+
+func chown(path string, owner *Owner) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// FileOwner returns the owner of path
+func FileOwner(path string) (*Owner, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("cannot read the owner of %s", path)
+	}
+	return &Owner{UID: int(stat.Uid), GID: int(stat.Gid)}, nil
+}
+
+func chown(path string, owner *Owner) error {
+	return os.Lchown(path, owner.UID, owner.GID)
+}
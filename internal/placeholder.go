@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PathRoot is a directory that uploaded paths are stored relative to, so
+// they install under the same directory on another machine
+type PathRoot struct {
+	Name string // placeholder without the $, e.g. HOME
+	Path string
+}
+
+// PathRoots returns the user defined roots from paths.roots followed by
+// $XDG_CONFIG_HOME, $XDG_DATA_HOME and $HOME
+func PathRoots(config *MydConfig) ([]PathRoot, error) {
+	var roots []PathRoot
+	for _, spec := range config.PathRoots {
+		root, err := parsePathRoot(spec)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	roots = append(roots,
+		PathRoot{"XDG_CONFIG_HOME", xdgDir("XDG_CONFIG_HOME", ".config")},
+		PathRoot{"XDG_DATA_HOME", xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))},
+		PathRoot{"HOME", os.Getenv("HOME")},
+	)
+
+	// A root without a location would match every path
+	kept := roots[:0]
+	for _, root := range roots {
+		if filepath.IsAbs(root.Path) {
+			root.Path = filepath.Clean(root.Path)
+			kept = append(kept, root)
+		}
+	}
+	return kept, nil
+}
+
+// parsePathRoot reads a paths.roots entry written as NAME=PATH
+func parsePathRoot(spec string) (PathRoot, error) {
+	name, path, ok := strings.Cut(spec, "=")
+	if !ok || !validPlaceholder(name) {
+		return PathRoot{}, fmt.Errorf("root %q must be written as NAME=PATH", spec)
+	}
+	path = os.ExpandEnv(path)
+	if !filepath.IsAbs(path) {
+		return PathRoot{}, fmt.Errorf("root %s must be an absolute path", name)
+	}
+	return PathRoot{Name: name, Path: path}, nil
+}
+
+func validPlaceholder(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Placeholder rewrites the start of path to the placeholder of the deepest
+// root containing it. Paths outside every root are returned unchanged.
+func Placeholder(roots []PathRoot, path string) string {
+	sorted := append([]PathRoot(nil), roots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Path) > len(sorted[j].Path)
+	})
+
+	path = filepath.Clean(path)
+	for _, root := range sorted {
+		if path == root.Path {
+			return "$" + root.Name
+		}
+		if strings.HasPrefix(path, root.Path+string(filepath.Separator)) {
+			return "$" + root.Name + path[len(root.Path):]
+		}
+	}
+	return path
+}
+
+// ExpandPlaceholder turns a stored path back into a location on this
+// machine, refusing anything that ends up outside the roots
+func ExpandPlaceholder(roots []PathRoot, target string) (string, error) {
+	path := target
+	if strings.HasPrefix(target, "$") {
+		name, rest := target[1:], ""
+		if slash := strings.Index(name, "/"); slash >= 0 {
+			name, rest = name[:slash], name[slash:]
+		}
+		name = strings.TrimSuffix(strings.TrimPrefix(name, "{"), "}")
+
+		path = ""
+		for _, root := range roots {
+			if root.Name == name {
+				path = root.Path + rest
+				break
+			}
+		}
+		if path == "" {
+			return "", fmt.Errorf("unknown placeholder $%s in %s, add it to paths.roots", name, target)
+		}
+	}
+	if strings.Contains(path, "$") {
+		return "", fmt.Errorf("unsupported placeholder in %s", target)
+	}
+
+	path = filepath.Clean(path)
+	for _, root := range roots {
+		if path == root.Path || strings.HasPrefix(path, root.Path+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the allowed roots", path)
+}
+
+// InstallTarget places installed files in another tree than the running
+// system, such as a mounted image or another user's home
+type InstallTarget struct {
+	Root string // prepended to every destination, empty for /
+	Home string // home directory inside Root, empty for $HOME
+}
+
+// Remapped reports whether the target differs from the running system
+func (t InstallTarget) Remapped() bool {
+	return t.Root != "" || t.Home != ""
+}
+
+// Roots returns the path roots as seen inside the target. With a home the
+// XDG directories are its defaults, the ones of the running user would not
+// apply to it.
+func (t InstallTarget) Roots(config *MydConfig) ([]PathRoot, error) {
+	roots, err := PathRoots(config)
+	if err != nil || t.Home == "" {
+		return roots, err
+	}
+	home := filepath.Clean(t.Home)
+	for i, root := range roots {
+		switch root.Name {
+		case "HOME":
+			roots[i].Path = home
+		case "XDG_CONFIG_HOME":
+			roots[i].Path = filepath.Join(home, ".config")
+		case "XDG_DATA_HOME":
+			roots[i].Path = filepath.Join(home, ".local", "share")
+		}
+	}
+	return roots, nil
+}
+
+// HomeDir returns the home directory of the target on the running system
+func (t InstallTarget) HomeDir() string {
+	home := t.Home
+	if home == "" {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join("/", t.Root, home)
+}
+
+// Resolve expands a stored path inside the target and returns where it is
+// written on the running system
+func (t InstallTarget) Resolve(config *MydConfig, stored string) (string, error) {
+	roots, err := t.Roots(config)
+	if err != nil {
+		return "", err
+	}
+	path, err := ExpandPlaceholder(roots, stored)
+	if err != nil || t.Root == "" {
+		return path, err
+	}
+	return filepath.Join(t.Root, path), nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func testPathRoots(t *testing.T, user ...string) []PathRoot {
+	t.Helper()
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "/data/me")
+	roots, err := PathRoots(&MydConfig{PathRoots: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return roots
+}
+
+func TestPlaceholder(t *testing.T) {
+	roots := testPathRoots(t, "WORK=/home/me/work", "USER=/home/me/.config/user")
+	tests := map[string]string{
+		"/home/me/.bashrc":                   "$HOME/.bashrc",
+		"/home/me":                           "$HOME",
+		"/home/me/.config/nvim/init.lua":     "$XDG_CONFIG_HOME/nvim/init.lua",
+		"/home/me/.configx/foo":              "$HOME/.configx/foo",
+		"/data/me/fonts":                     "$XDG_DATA_HOME/fonts",
+		"/home/me/work/notes/../todo.txt":    "$WORK/todo.txt",
+		"/home/me/.config/user/alacritty.ym": "$USER/alacritty.ym",
+		"/etc/hosts":                         "/etc/hosts",
+		"/home/mean/.bashrc":                 "/home/mean/.bashrc",
+	}
+	for path, want := range tests {
+		if got := Placeholder(roots, path); got != want {
+			t.Errorf("Placeholder(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestExpandPlaceholder(t *testing.T) {
+	roots := testPathRoots(t, "USER=/home/me/.config/user")
+	tests := []struct {
+		stored string
+		want   string
+		err    string
+	}{
+		{stored: "$HOME/.bashrc", want: "/home/me/.bashrc"},
+		{stored: "${HOME}/.bashrc", want: "/home/me/.bashrc"},
+		{stored: "$XDG_CONFIG_HOME/nvim", want: "/home/me/.config/nvim"},
+		{stored: "$USER/alacritty.yml", want: "/home/me/.config/user/alacritty.yml"},
+		{stored: "$HOME", want: "/home/me"},
+		{stored: "/home/me/.bashrc", want: "/home/me/.bashrc"},
+		{stored: "$HOME/.config/../.bashrc", want: "/home/me/.bashrc"},
+		{stored: "$USERacritty/config.yml", err: "unknown placeholder $USERacritty"},
+		{stored: "$NOPE/file", err: "unknown placeholder $NOPE"},
+		{stored: "$HOME/$USER/file", err: "unsupported placeholder"},
+		{stored: "$HOME/../../etc/passwd", err: "outside the allowed roots"},
+		{stored: "$XDG_DATA_HOME/../../etc", err: "outside the allowed roots"},
+		{stored: "/etc/hosts", err: "outside the allowed roots"},
+		{stored: "/home/mean/.bashrc", err: "outside the allowed roots"},
+	}
+	for _, test := range tests {
+		got, err := ExpandPlaceholder(roots, test.stored)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ExpandPlaceholder(%q) = %q, %v, want an error mentioning %q", test.stored, got, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ExpandPlaceholder(%q) = %q, %v, want %q", test.stored, got, err, test.want)
+		}
+	}
+}
+
+func TestPathRootsRejectsInvalidRoots(t *testing.T) {
+	for _, spec := range []string{"WORK", "=/srv", "1WORK=/srv", "MY-ROOT=/srv", "WORK=relative/dir"} {
+		if _, err := PathRoots(&MydConfig{PathRoots: []string{spec}}); err == nil {
+			t.Errorf("paths.roots entry %q was accepted", spec)
+		}
+	}
+
+	t.Setenv("SRV", "/srv")
+	root, err := parsePathRoot("WORK=$SRV/work")
+	if err != nil || root != (PathRoot{"WORK", "/srv/work"}) {
+		t.Errorf("parsePathRoot() = %v, %v", root, err)
+	}
+}
+
+func TestInstallTargetResolve(t *testing.T) {
+	testPathRoots(t)
+	config := &MydConfig{}
+	tests := []struct {
+		target InstallTarget
+		stored string
+		want   string
+	}{
+		{InstallTarget{}, "$HOME/.bashrc", "/home/me/.bashrc"},
+		{InstallTarget{Root: "/mnt"}, "$HOME/.bashrc", "/mnt/home/me/.bashrc"},
+		{InstallTarget{Home: "/home/other"}, "$XDG_CONFIG_HOME/nvim", "/home/other/.config/nvim"},
+		{InstallTarget{Home: "/home/other"}, "$XDG_DATA_HOME/fonts", "/home/other/.local/share/fonts"},
+		{InstallTarget{Root: "/mnt", Home: "/home/other"}, "$HOME/.bashrc", "/mnt/home/other/.bashrc"},
+	}
+	for _, test := range tests {
+		got, err := test.target.Resolve(config, test.stored)
+		if err != nil || got != test.want {
+			t.Errorf("%+v.Resolve(%q) = %q, %v, want %q", test.target, test.stored, got, err, test.want)
+		}
+	}
+
+	if _, err := (InstallTarget{Home: "/home/other"}).Resolve(config, "/home/me/.bashrc"); err == nil {
+		t.Error("a path in the running user's home resolved inside another home")
+	}
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SkippedFile is a file left out of an upload by the file policy
+type SkippedFile struct {
+	Path   string
+	Size   int64
+	Reason string
+}
+
+// FilePolicy decides which files are too large or binary to commit directly,
+// and whether those are skipped or stored through Git LFS
+type FilePolicy struct {
+	MaxSize    int64 // 0 disables the size check
+	SkipBinary bool
+	UseLFS     bool
+
+	Skipped []SkippedFile
+	LFS     []string // repository paths stored through Git LFS
+}
+
+// NewFilePolicy builds the policy from the config. LFS is used when the
+// upstream already stores files through it, or when files.use_lfs turns it
+// on, and only when git-lfs is actually installed.
+func NewFilePolicy(config *MydConfig) *FilePolicy {
+	repoPath := filepath.Join(os.ExpandEnv(config.StoragePath), config.UpstreamName)
+	upstreamLFS := UsesLFS(repoPath)
+	policy := &FilePolicy{
+		MaxSize:    int64(config.MaxFileSizeMB) * 1024 * 1024,
+		SkipBinary: config.DetectBinaryFiles,
+		UseLFS:     config.UseGitLFS || upstreamLFS,
+	}
+	if policy.UseLFS {
+		if _, err := Git("", "lfs", "version"); err != nil {
+			reason := "files.use_lfs is set"
+			if upstreamLFS {
+				reason = "the upstream uses Git LFS"
+			}
+			Warn("%s but git-lfs is not installed, offending files will be skipped\n", reason)
+			policy.UseLFS = false
+		}
+	}
+	return policy
+}
+
+// UsesLFS reports whether the last upload in the repository at repoPath
+// tracks files through Git LFS. The committed .gitattributes is read since
+// upload clears the working tree before copying.
+func UsesLFS(repoPath string) bool {
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return false
+	}
+	attributes, err := GitOutput(repoPath, "show", "HEAD:.gitattributes")
+	return err == nil && bytes.Contains(attributes, []byte("filter=lfs"))
+}
+
+// Allow reports whether the file at path may be copied to repoRel in the
+// repository. Offending files are either recorded for LFS or skipped.
+func (p *FilePolicy) Allow(path, repoRel string, size int64) bool {
+	if p == nil {
+		return true
+	}
+
+	reason := ""
+	if p.MaxSize > 0 && size > p.MaxSize {
+		reason = fmt.Sprintf("larger than %s", FormatSize(p.MaxSize))
+	} else if p.SkipBinary && IsBinaryFile(path) {
+		reason = "binary file"
+	}
+	if reason == "" {
+		return true
+	}
+
+	if p.UseLFS {
+		p.LFS = append(p.LFS, filepath.ToSlash(repoRel))
+		return true
+	}
+
+	Warn("Skipping %s: %s\n", path, reason)
+	p.Skipped = append(p.Skipped, SkippedFile{Path: path, Size: size, Reason: reason})
+	return false
+}
+
+// IsBinaryFile reports whether the start of a file contains a NUL byte, the
+// same heuristic git uses
+func IsBinaryFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, 8000)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0
+}
+
+// WriteLFSAttributes tracks the recorded LFS paths in the repository's .gitattributes
+func (p *FilePolicy) WriteLFSAttributes(repoPath string) error {
+	if p == nil || len(p.LFS) == 0 {
+		return nil
+	}
+
+	sort.Strings(p.LFS)
+	var b strings.Builder
+	for _, path := range p.LFS {
+		// Spaces would split the pattern, gitattributes spells them as a class
+		escaped := strings.ReplaceAll(path, " ", "[[:space:]]")
+		fmt.Fprintf(&b, "/%s filter=lfs diff=lfs merge=lfs -text\n", escaped)
+	}
+	return os.WriteFile(filepath.Join(repoPath, ".gitattributes"), []byte(b.String()), 0644)
+}
+
+// SkippedListPath returns the location of the list of files skipped by the last upload
+func SkippedListPath(config *MydConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "skipped.txt")
+}
+
+// SaveSkipped records the skipped files so myd status can report them
+func (p *FilePolicy) SaveSkipped(config *MydConfig) error {
+	var b strings.Builder
+	for _, skipped := range p.Skipped {
+		fmt.Fprintf(&b, "%s\t%d\t%s\n", skipped.Path, skipped.Size, skipped.Reason)
+	}
+	return os.WriteFile(SkippedListPath(config), []byte(b.String()), 0644)
+}
+
+// LoadSkipped reads the files skipped by the last upload
+func LoadSkipped(config *MydConfig) []SkippedFile {
+	data, err := os.ReadFile(SkippedListPath(config))
+	if err != nil {
+		return nil
+	}
+
+	var skipped []SkippedFile
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		size, _ := strconv.ParseInt(parts[1], 10, 64)
+		skipped = append(skipped, SkippedFile{Path: parts[0], Size: size, Reason: parts[2]})
+	}
+	return skipped
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Preview stages a copy of the live tracked files against the repository
+// using a separate index, so diffs can be shown without touching the
+// repository's own working tree or index
+type Preview struct {
+	repoPath string
+	workTree string
+	index    string
+}
+
+// NewPreview stages workTree, which holds freshly copied tracked files
+func NewPreview(repoPath, workTree string) (*Preview, error) {
+	p := &Preview{repoPath: repoPath, workTree: workTree, index: workTree + ".index"}
+	os.Remove(p.index)
+
+	if _, err := p.Git("rev-parse", "--verify", "HEAD"); err == nil {
+		if _, err := p.Git("read-tree", "HEAD"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.Git("add", "-A"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Command builds a git command running against the preview
+func (p *Preview) Command(args ...string) *exec.Cmd {
+	base := []string{"--git-dir", filepath.Join(p.repoPath, ".git"), "--work-tree", p.workTree}
+	cmd := exec.Command("git", append(base, args...)...)
+	cmd.Dir = p.workTree
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+p.index)
+	return cmd
+}
+
+// Git runs a git command against the preview and returns its trimmed output
+func (p *Preview) Git(args ...string) (string, error) {
+	output, err := p.Command(args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Changes lists what an upload would change in the repository
+func (p *Preview) Changes() ([]FileChange, error) {
+	output, err := p.Git("-c", "core.quotePath=false", "diff", "--cached", "--name-status", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameStatus(output), nil
+}
+
+// Close removes the staged copy
+func (p *Preview) Close() {
+	os.Remove(p.index)
+	os.RemoveAll(p.workTree)
+}
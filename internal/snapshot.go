@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is one upload commit in the dotfiles repository
+type Snapshot struct {
+	Hash    string
+	Date    time.Time
+	Host    string
+	Subject string
+	Paths   []string // top level entries changed by the snapshot
+}
+
+// Short returns the abbreviated commit hash
+func (s Snapshot) Short() string {
+	if len(s.Hash) > 7 {
+		return s.Hash[:7]
+	}
+	return s.Hash
+}
+
+// ListSnapshots returns the snapshots of the repository, newest first.
+// When paths are given only snapshots touching them are listed.
+func ListSnapshots(repoPath string, limit int, paths ...string) ([]Snapshot, error) {
+	args := []string{
+		"-c", "core.quotePath=false", "log", "--name-only",
+		"--format=%x1e%H%x1f%aI%x1f%s%x1f%(trailers:key=" + HostTrailer + ",valueonly,separator=)",
+	}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("-n%d", limit))
+	}
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+
+	output, err := Git(repoPath, args...)
+	if err != nil {
+		// A repository without commits has no history yet
+		if _, headErr := Git(repoPath, "rev-parse", "--verify", "HEAD"); headErr != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, record := range strings.Split(output, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+
+		date, _ := time.Parse(time.RFC3339, fields[1])
+		snapshot := Snapshot{
+			Hash:    fields[0],
+			Date:    date,
+			Subject: fields[2],
+			Host:    strings.TrimSpace(fields[3]),
+		}
+
+		seen := make(map[string]bool)
+		for _, file := range lines[1:] {
+			if file = strings.TrimSpace(file); file == "" || file == ".original_path" {
+				continue
+			}
+			name := strings.SplitN(file, "/", 2)[0]
+			if !seen[name] {
+				seen[name] = true
+				snapshot.Paths = append(snapshot.Paths, name)
+			}
+		}
+		sort.Strings(snapshot.Paths)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// ResolveSnapshot turns a commit hash or revision into a full commit hash
+func ResolveSnapshot(repoPath, rev string) (string, error) {
+	hash, err := Git(repoPath, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown snapshot %q", rev)
+	}
+	return hash, nil
+}
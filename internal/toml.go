@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tomlDoc is a config file kept as its lines, so single values can be read
+// and changed without losing comments or the order of keys. It understands
+// the subset of TOML the config uses: [sections], bare or dotted keys,
+// strings, integers, booleans and arrays.
+type tomlDoc struct {
+	lines []*tomlLine
+}
+
+type tomlLine struct {
+	raw     string // original text, used for unchanged lines
+	number  int    // first line number in the file
+	section string // section the line belongs to
+	header  bool
+	key     string
+	value   string // value as written, arrays may span several lines
+	comment string // trailing comment, including the #
+	changed bool
+}
+
+func (l *tomlLine) String() string {
+	if !l.changed {
+		return l.raw
+	}
+	line := fmt.Sprintf("%s = %s", l.key, l.value)
+	if l.comment != "" {
+		line += " " + l.comment
+	}
+	return line
+}
+
+// parseTOML splits data into lines, reporting the first syntax error
+func parseTOML(data string) (*tomlDoc, error) {
+	doc := &tomlDoc{}
+	section := ""
+	rawLines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	for i := 0; i < len(rawLines); i++ {
+		raw := rawLines[i]
+		line := &tomlLine{raw: raw, number: i + 1, section: section}
+		trimmed := strings.TrimSpace(raw)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "["):
+			end := strings.Index(trimmed, "]")
+			if end < 0 || strings.HasPrefix(trimmed, "[[") {
+				return nil, &ConfigError{Line: line.number, Err: fmt.Errorf("invalid section header %q", trimmed)}
+			}
+			if rest := strings.TrimSpace(trimmed[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, &ConfigError{Line: line.number, Err: fmt.Errorf("unexpected %q after section header", rest)}
+			}
+			section = strings.TrimSpace(trimmed[1:end])
+			line.section, line.header = section, true
+		default:
+			eq := strings.Index(trimmed, "=")
+			if eq < 0 {
+				return nil, &ConfigError{Line: line.number, Err: fmt.Errorf("expected key = value, got %q", trimmed)}
+			}
+			line.key = strings.TrimSpace(trimmed[:eq])
+			if !validKey(line.key) {
+				return nil, &ConfigError{Line: line.number, Err: fmt.Errorf("invalid key %q", line.key)}
+			}
+
+			// Arrays may continue on the following lines
+			text := strings.TrimSpace(trimmed[eq+1:])
+			value, rest, err := scanValue(text)
+			for err == errUnterminatedArray && i+1 < len(rawLines) {
+				i++
+				line.raw += "\n" + rawLines[i]
+				text += "\n" + rawLines[i]
+				value, rest, err = scanValue(text)
+			}
+			if err != nil {
+				return nil, &ConfigError{Line: line.number, Err: err}
+			}
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, &ConfigError{Line: line.number, Err: fmt.Errorf("unexpected %q after value", rest)}
+			}
+			if _, err := decodeValue(value); err != nil {
+				return nil, &ConfigError{Line: line.number, Err: err}
+			}
+			line.value, line.comment = value, rest
+		}
+		doc.lines = append(doc.lines, line)
+	}
+	return doc, nil
+}
+
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, part := range strings.Split(key, ".") {
+		if part == "" {
+			return false
+		}
+		for _, r := range part {
+			if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var errUnterminatedArray = fmt.Errorf("unterminated array")
+
+// scanValue splits the value at the start of s from the rest of the line
+func scanValue(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+
+	switch s[0] {
+	case '"', '\'':
+		end, err := stringEnd(s)
+		if err != nil {
+			return "", "", err
+		}
+		return s[:end], s[end:], nil
+	case '[':
+		depth := 0
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '"', '\'':
+				end, err := stringEnd(s[i:])
+				if err != nil {
+					return "", "", err
+				}
+				i += end - 1
+			case '#':
+				// Comments inside multi-line arrays run to the end of the line
+				if newline := strings.Index(s[i:], "\n"); newline >= 0 {
+					i += newline
+				} else {
+					return "", "", errUnterminatedArray
+				}
+			case '[':
+				depth++
+			case ']':
+				depth--
+				if depth == 0 {
+					return s[:i+1], s[i+1:], nil
+				}
+			}
+		}
+		return "", "", errUnterminatedArray
+	}
+
+	end := strings.IndexAny(s, " \t#")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:], nil
+}
+
+// stringEnd returns the index just past the quoted string at the start of s
+func stringEnd(s string) (int, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\n':
+			return 0, fmt.Errorf("unterminated string")
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string")
+}
+
+// decodeValue turns a value as written into a string, int64, bool or []interface{}
+func decodeValue(value string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		decoded, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+		return decoded, nil
+	case strings.HasPrefix(value, "'"):
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, "["):
+		return decodeArray(value)
+	case value == "true":
+		return true, nil
+	case value == "false":
+		return false, nil
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s, strings need quotes", value)
+	}
+	return n, nil
+}
+
+func decodeArray(value string) ([]interface{}, error) {
+	items := []interface{}{}
+	s := strings.TrimSpace(value[1 : len(value)-1])
+	for {
+		// Skip separators and comments between items
+		for {
+			s = strings.TrimLeft(s, " \t\r\n")
+			if strings.HasPrefix(s, "#") {
+				if newline := strings.Index(s, "\n"); newline >= 0 {
+					s = s[newline:]
+					continue
+				}
+				s = ""
+			}
+			break
+		}
+		if s == "" {
+			return items, nil
+		}
+
+		item, rest, err := scanValue(s)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeValue(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, decoded)
+
+		s = strings.TrimLeft(rest, " \t\r\n")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		} else if strings.TrimSpace(s) != "" && !strings.HasPrefix(s, "#") {
+			return nil, fmt.Errorf("expected , between array items")
+		}
+	}
+}
+
+// encodeValue writes a Go value the way decodeValue reads it
+func encodeValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Duration:
+		return strconv.Quote(v.String())
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+// splitKey splits a dotted config key into its section and key, the last
+// part being the key
+func splitKey(name string) (string, string) {
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		return name[:dot], name[dot+1:]
+	}
+	return "", name
+}
+
+func (d *tomlDoc) get(section, key string) *tomlLine {
+	for _, line := range d.lines {
+		if line.section == section && line.key == key {
+			return line
+		}
+	}
+	return nil
+}
+
+func (d *tomlDoc) hasSection(section string) bool {
+	for _, line := range d.lines {
+		if line.header && line.section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// set changes a value in place, or adds the key after the last key of its
+// section, creating the section at the end of the file when needed
+func (d *tomlDoc) set(section, key, value string) {
+	if line := d.get(section, key); line != nil {
+		line.value, line.changed = value, true
+		return
+	}
+
+	line := &tomlLine{section: section, key: key, value: value, changed: true}
+	insert := -1
+	for i, existing := range d.lines {
+		if existing.section != section {
+			if section == "" && existing.header && insert < 0 {
+				// Keep the blank line before the first section
+				for insert = i; insert > 0 && strings.TrimSpace(d.lines[insert-1].String()) == ""; insert-- {
+				}
+			}
+			continue
+		}
+		if existing.header || existing.key != "" {
+			insert = i + 1
+		}
+	}
+
+	if insert < 0 {
+		if section == "" {
+			insert = len(d.lines)
+		} else {
+			if len(d.lines) > 0 && strings.TrimSpace(d.lines[len(d.lines)-1].String()) != "" {
+				d.lines = append(d.lines, &tomlLine{})
+			}
+			d.lines = append(d.lines, &tomlLine{raw: "[" + section + "]", section: section, header: true})
+			insert = len(d.lines)
+		}
+	}
+	d.lines = append(d.lines[:insert], append([]*tomlLine{line}, d.lines[insert:]...)...)
+}
+
+func (d *tomlDoc) String() string {
+	var b strings.Builder
+	for _, line := range d.lines {
+		b.WriteString(line.String() + "\n")
+	}
+	return b.String()
+}
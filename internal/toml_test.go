@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		err   string
+	}{
+		{"missing equals", "storage_path = \"\"\nnope\n", 2, "expected key = value"},
+		{"unquoted string", "[upstream]\nname = dotfiles\n", 2, "strings need quotes"},
+		{"unterminated string", "name = \"dotfiles\n", 1, "unterminated string"},
+		{"unterminated array", "patterns = [\"a\",\n\"b\"\n", 1, "unterminated array"},
+		{"missing comma", "patterns = [\"a\" \"b\"]\n", 1, "expected , between array items"},
+		{"invalid key", "bad key = 1\n", 1, "invalid key"},
+		{"empty key part", "upstream..name = 1\n", 1, "invalid key"},
+		{"array of tables", "[[profiles]]\n", 1, "invalid section header"},
+		{"text after header", "[upstream] name\n", 1, "after section header"},
+		{"text after value", "max_size_mb = 50 60\n", 1, "after value"},
+		{"missing value", "name =\n", 1, "missing value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTOML(test.input)
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("parseTOML() error = %v, want a ConfigError", err)
+			}
+			if configErr.Line != test.line {
+				t.Errorf("error on line %d, want %d", configErr.Line, test.line)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error %q does not mention %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseTOMLValues(t *testing.T) {
+	input := `# comment
+storage_path = "" # trailing comment
+[upstream]
+name = "dotfiles"
+[files]
+max_size_mb = 1_000
+use_lfs = true
+[ignore]
+patterns = [
+  "*.log", # logs
+  '.DS_Store',
+]
+[commit]
+template = "{{.Subject}}\n\n{{.Body}}"
+`
+	doc, err := parseTOML(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.String(); got != input {
+		t.Errorf("unchanged document does not round trip:\n%s", got)
+	}
+
+	tests := []struct {
+		section, key string
+		want         interface{}
+	}{
+		{"", "storage_path", ""},
+		{"upstream", "name", "dotfiles"},
+		{"files", "max_size_mb", int64(1000)},
+		{"files", "use_lfs", true},
+		{"ignore", "patterns", []interface{}{"*.log", ".DS_Store"}},
+		{"commit", "template", "{{.Subject}}\n\n{{.Body}}"},
+	}
+	for _, test := range tests {
+		line := doc.get(test.section, test.key)
+		if line == nil {
+			t.Errorf("%s.%s not found", test.section, test.key)
+			continue
+		}
+		got, err := decodeValue(line.value)
+		if err != nil {
+			t.Errorf("%s.%s: %v", test.section, test.key, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s.%s = %#v, want %#v", test.section, test.key, got, test.want)
+		}
+	}
+	if line := doc.get("", "storage_path"); line.comment != "# trailing comment" {
+		t.Errorf("trailing comment = %q", line.comment)
+	}
+}
+
+func TestTomlDocSet(t *testing.T) {
+	input := `# myd
+storage_path = ""
+
+[upstream]
+# GitHub repository
+name = "dotfiles" # keep me
+
+[files]
+max_size_mb = 50
+`
+	tests := []struct {
+		name                string
+		section, key, value string
+		want                string
+	}{
+		{
+			name:    "changes a value in place",
+			section: "upstream", key: "name", value: `"other"`,
+			want: strings.Replace(input, `name = "dotfiles" # keep me`, `name = "other" # keep me`, 1),
+		},
+		{
+			name:    "adds a key after the last one of its section",
+			section: "upstream", key: "username", value: `"me"`,
+			want: strings.Replace(input, "# keep me\n", "# keep me\nusername = \"me\"\n", 1),
+		},
+		{
+			name:    "adds a top level key before the first section",
+			section: "", key: "profile", value: `""`,
+			want: strings.Replace(input, "storage_path = \"\"\n", "storage_path = \"\"\nprofile = \"\"\n", 1),
+		},
+		{
+			name:    "creates a missing section at the end",
+			section: "hooks", key: "timeout", value: `"5m"`,
+			want: input + "\n[hooks]\ntimeout = \"5m\"\n",
+		},
+		{
+			name:    "creates a profile section",
+			section: "profiles.work", key: "upstream.name", value: `"work"`,
+			want: input + "\n[profiles.work]\nupstream.name = \"work\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := parseTOML(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc.set(test.section, test.key, test.value)
+			if got := doc.String(); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+			if _, err := parseTOML(doc.String()); err != nil {
+				t.Errorf("result does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"a \"quoted\"\nline", `"a \"quoted\"\nline"`},
+		{true, "true"},
+		{50, "50"},
+		{[]string{"*.log", "a b"}, `["*.log", "a b"]`},
+		{[]string{}, "[]"},
+	}
+	for _, test := range tests {
+		got := encodeValue(test.value)
+		if got != test.want {
+			t.Errorf("encodeValue(%#v) = %s, want %s", test.value, got, test.want)
+		}
+		if _, err := decodeValue(got); err != nil {
+			t.Errorf("decodeValue(%s): %v", got, err)
+		}
+	}
+}
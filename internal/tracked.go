@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UploadListPath returns the location of toupload.txt
+func UploadListPath(config *MydConfig) string {
+	return filepath.Join(os.ExpandEnv(config.StoragePath), "toupload.txt")
+}
+
+// LoadTrackedPaths reads the tracked paths and patterns from toupload.txt
+func LoadTrackedPaths(config *MydConfig) []string {
+	data, err := os.ReadFile(UploadListPath(config))
+	if err != nil {
+		return []string{}
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths
+}
+
+// SaveTrackedPaths rewrites toupload.txt with the given paths
+func SaveTrackedPaths(config *MydConfig, paths []string) error {
+	content := strings.Join(paths, "\n")
+	if len(paths) > 0 {
+		content += "\n"
+	}
+	return os.WriteFile(UploadListPath(config), []byte(content), 0644)
+}
+
+// AppendTrackedPaths adds paths to the end of toupload.txt
+func AppendTrackedPaths(config *MydConfig, paths []string) error {
+	if err := os.MkdirAll(filepath.Dir(UploadListPath(config)), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(UploadListPath(config), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, path := range paths {
+		if _, err := f.WriteString(path + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepoEntryName returns the name a tracked path or pattern is stored under in the repository
+func RepoEntryName(tracked string) string {
+	if IsGlob(tracked) {
+		return GlobEntryName(tracked)
+	}
+	return filepath.Base(tracked)
+}